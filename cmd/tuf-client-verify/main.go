@@ -1,61 +1,273 @@
 package main
 
 import (
-	"log"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/matglas/tuf-client-verify/internal/config"
+	"github.com/matglas/tuf-client-verify/internal/logging"
+	"github.com/matglas/tuf-client-verify/internal/metrics"
+	"github.com/matglas/tuf-client-verify/internal/tracing"
+	"github.com/matglas/tuf-client-verify/internal/trustroot"
 	"github.com/matglas/tuf-client-verify/internal/tuf"
 )
 
-const (
-	DefaultPort     = "8080"
-	DefaultRepoPath = "testdata/repository"
-)
+// traceFlushTimeout bounds how long pending spans are flushed for on exit
+const traceFlushTimeout = 5 * time.Second
+
+// maxRequestIDLength bounds the length of request IDs accepted from clients
+const maxRequestIDLength = 128
+
+// tufRefresher holds the current verified TUF client and keeps it up to date
+var tufRefresher *tuf.Refresher
+
+// serviceMetrics records authorization metrics for /metrics
+var serviceMetrics *metrics.Metrics
+
+// tracer creates the spans of /auth requests
+var tracer = otel.Tracer("github.com/matglas/tuf-client-verify/cmd/tuf-client-verify")
+
+// headers names the request headers set by the proxy
+var headers config.HeadersConfig
 
-var tufClient *tuf.Client
+// allowedSampler selects the allowed decisions that are logged
+var allowedSampler *logging.Sampler
 
 // authHandler handles nginx auth_request calls with TUF verification
 func authHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	result := metrics.ResultError
+	defer func() {
+		serviceMetrics.ObserveAuth(result, time.Since(start))
+	}()
+
 	// Extract the original URI from nginx headers
-	originalURI := r.Header.Get("X-Original-URI")
+	originalURI := r.Header.Get(headers.OriginalURI)
 	if originalURI == "" {
 		originalURI = r.URL.Path
 	}
+	method := r.Header.Get(headers.OriginalMethod)
+	if method == "" {
+		method = r.Method
+	}
+	host := r.Header.Get(headers.OriginalHost)
+	if host == "" {
+		host = r.Host
+	}
+	ip := clientIP(r)
+
+	// Echo the request ID so the proxy and this service log the same one
+	requestID := requestIDFor(r)
+	w.Header().Set(headers.RequestID, requestID)
 
-	log.Printf("Auth request for: %s (Method: %s)", originalURI, r.Method)
+	// Continue the trace of the original request, which nginx passes on to
+	// the auth subrequest in its traceparent header
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracer.Start(ctx, "auth", trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+		attribute.String("http.request.method", method),
+		attribute.String("url.path", originalURI),
+		attribute.String("client.address", ip),
+		attribute.String("request.id", requestID),
+	))
+	defer span.End()
+
+	logger := slog.With(
+		"request_id", requestID,
+		"uri", originalURI,
+		"method", method,
+		"client_ip", ip,
+	)
+	if spanContext := span.SpanContext(); spanContext.IsValid() {
+		logger = logger.With("trace_id", spanContext.TraceID().String())
+	}
+	logger.Debug("Auth request received")
 
 	// Verify path against TUF metadata
-	allowed, err := tufClient.VerifyPath(originalURI)
+	decision, err := tufRefresher.DecideRequest(ctx, tuf.DecisionRequest{Path: originalURI, Method: method, Host: host})
 	if err != nil {
-		log.Printf("TUF verification error for %s: %v", originalURI, err)
+		logger.Error("TUF verification failed", "error", err,
+			"latency_ms", logging.Milliseconds(time.Since(start)))
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(attribute.Int("http.response.status_code", http.StatusInternalServerError))
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte("Internal Server Error"))
 		return
 	}
+	serviceMetrics.ObserveDecision(decision)
 
-	if allowed {
-		log.Printf("✅ ALLOWED: %s", originalURI)
+	if decision.Allowed {
+		result = metrics.ResultAllowed
+		if allowedSampler.Sample() {
+			logger.Info("Auth request allowed",
+				"decision", result,
+				"role", decision.RoleChain[len(decision.RoleChain)-1],
+				"role_chain", decision.RoleChain,
+				"latency_ms", logging.Milliseconds(time.Since(start)))
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", http.StatusOK))
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	} else {
-		log.Printf("❌ DENIED: %s", originalURI)
+		result = metrics.ResultDenied
+		logger.Info("Auth request denied",
+			"decision", result,
+			"reason", decision.Reason,
+			"consulted_roles", decision.ConsultedRoles,
+			"latency_ms", logging.Milliseconds(time.Since(start)))
+		span.SetAttributes(attribute.Int("http.response.status_code", http.StatusForbidden))
 		w.WriteHeader(http.StatusForbidden)
 		w.Write([]byte("Forbidden"))
 	}
 }
 
-// healthHandler provides a simple health check endpoint
+// requestIDFor returns the request ID of r, or a new one if r has none or
+// its ID is not up to maxRequestIDLength letters, digits, '.', '_' and '-'.
+// The ID is logged and echoed, so arbitrary client input is never trusted.
+func requestIDFor(r *http.Request) string {
+	requestID := r.Header.Get(headers.RequestID)
+	if !validRequestID(requestID) {
+		return newRequestID()
+	}
+
+	return requestID
+}
+
+// validRequestID reports whether id is a non-empty request ID of at most
+// maxRequestIDLength characters from [A-Za-z0-9._-]
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		switch c := id[i]; {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// newRequestID returns a random request ID for requests that arrive without
+// a valid one
+func newRequestID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(id[:])
+}
+
+// clientIP returns the original client address from the proxy header, or
+// the address of the connection
+func clientIP(r *http.Request) string {
+	if ip := r.Header.Get(headers.ClientIP); ip != "" {
+		return ip
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// healthHandler provides a simple health check endpoint. While the last
+// metadata refresh has failed it reports degraded, and it fails once the
+// service can no longer authorize any path.
 func healthHandler(w http.ResponseWriter, r *http.Request) {
+	status := tufRefresher.Status()
+
+	if !status.Authorizing {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("unhealthy: no verified metadata to authorize from"))
+		return
+	}
+
+	// The refresh error may name internal hosts and paths, so it is only
+	// logged, by logRefresh
 	w.WriteHeader(http.StatusOK)
+	if status.Degraded {
+		w.Write([]byte(fmt.Sprintf("degraded: serving last verified metadata until %s after %d failed refreshes",
+			status.Expires.UTC().Format(time.RFC3339), status.ConsecutiveFailures)))
+		return
+	}
 	w.Write([]byte("healthy"))
 }
 
-// debugHandler provides debug information about allowed paths
+// debugResponse is the body of a /debug request without a path
+type debugResponse struct {
+	AllowedPaths  []string                  `json:"allowed_paths"`
+	Delegations   map[string][]string       `json:"delegations"`
+	RootVersion   int64                     `json:"root_version"`
+	Roles         map[string]tuf.RoleStatus `json:"roles"`
+	TopLevelRoles map[string]tuf.RoleStatus `json:"top_level_roles"`
+	DecisionCache tuf.CacheStats            `json:"decision_cache"`
+}
+
+// debugHandler provides debug information about allowed paths, or the full
+// decision for a single path given as ?path=
 func debugHandler(w http.ResponseWriter, r *http.Request) {
+	if path := r.URL.Query().Get("path"); path != "" {
+		decisionHandler(w, path)
+		return
+	}
+
+	tufClient := tufRefresher.Client()
 	paths, err := tufClient.GetAllowedPaths()
 	if err != nil {
-		log.Printf("Error getting allowed paths: %v", err)
+		slog.Error("Failed to get allowed paths", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("Internal Server Error"))
+		return
+	}
+	if paths == nil {
+		paths = []string{}
+	}
+
+	response := debugResponse{
+		AllowedPaths:  paths,
+		Delegations:   tufClient.GetDelegationInfo(),
+		RootVersion:   tufClient.GetRootInfo().Version,
+		Roles:         tufClient.GetRoleStatus(),
+		TopLevelRoles: tufClient.GetTopLevelStatus(),
+		DecisionCache: tufRefresher.CacheStats(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("Failed to encode debug information", "error", err)
+	}
+}
+
+// decisionHandler writes the decision for path as JSON
+func decisionHandler(w http.ResponseWriter, path string) {
+	decision, err := tufRefresher.Decide(path)
+	if err != nil {
+		slog.Error("TUF verification failed", "uri", path, "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte("Internal Server Error"))
 		return
@@ -63,64 +275,186 @@ func debugHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(decision); err != nil {
+		slog.Error("Failed to encode decision", "uri", path, "error", err)
+	}
+}
 
-	response := `{"allowed_paths": [`
-	for i, path := range paths {
-		if i > 0 {
-			response += ","
+// logRefresh logs the outcome of a metadata reload
+func logRefresh(client *tuf.Client, err error) {
+	if err != nil {
+		status := tufRefresher.Status()
+		if status.Authorizing {
+			slog.Warn("TUF metadata refresh failed, serving last verified state until it expires",
+				"degraded", true, "expires", status.Expires.UTC(),
+				"consecutive_failures", status.ConsecutiveFailures, "error", err)
+		} else {
+			slog.Error("TUF metadata refresh failed, denying all paths until a refresh succeeds",
+				"degraded", true, "consecutive_failures", status.ConsecutiveFailures, "error", err)
 		}
-		response += `"` + path + `"`
+		return
 	}
-	response += `], "delegations": {`
+	slog.Info("TUF metadata refreshed", "root_version", client.GetRootInfo().Version)
+	logLoadErrors(client)
+}
 
-	delegations := tufClient.GetDelegationInfo()
-	first := true
-	for role, patterns := range delegations {
-		if !first {
-			response += ","
+// logLoadErrors logs the delegated roles whose metadata was unavailable
+func logLoadErrors(client *tuf.Client) {
+	for _, roleErr := range client.LoadErrors() {
+		logger := slog.With("role", roleErr.Role, "state", roleErr.State)
+		if roleErr.Delegator != "" {
+			logger = logger.With("delegator", roleErr.Delegator)
 		}
-		first = false
-		response += `"` + role + `": [`
-		for j, pattern := range patterns {
-			if j > 0 {
-				response += ","
-			}
-			response += `"` + pattern + `"`
+		logger.Warn("Delegated role unavailable, denying paths it is trusted for", "error", roleErr.Err)
+	}
+}
+
+// fatal logs msg with err and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// loadConfig loads and validates the configuration from args and the
+// environment, and converts it to the TUF client configuration
+func loadConfig(name string, args []string) (*config.Config, tuf.Config, error) {
+	cfg, err := config.Load(name, args, os.LookupEnv)
+	if err != nil {
+		return nil, tuf.Config{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, tuf.Config{}, err
+	}
+
+	// The trust anchor is compiled in so the on-disk repository cannot swap it
+	rootBytes := trustroot.Root()
+	if rootBytes == nil {
+		return nil, tuf.Config{}, fmt.Errorf("no embedded root of trust: build with internal/trustroot/embedded/root.json (see scripts/generate-tuf-repo.go)")
+	}
+
+	tufConfig, err := cfg.TUF(rootBytes)
+	if err != nil {
+		return nil, tuf.Config{}, err
+	}
+	if err := tuf.ValidateConfig(tufConfig); err != nil {
+		return nil, tuf.Config{}, err
+	}
+
+	return cfg, tufConfig, nil
+}
+
+// configCommand runs the config subcommands and returns the exit code
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprintln(os.Stderr, "usage: tuf-client-verify config validate [flags]")
+		return 2
+	}
+
+	if _, _, err := loadConfig("config validate", args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
 		}
-		response += `]`
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		return 1
 	}
-	response += `}}`
 
-	w.Write([]byte(response))
+	fmt.Println("Configuration is valid")
+	return 0
 }
 
 func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = DefaultPort
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(configCommand(os.Args[2:]))
+	}
+
+	serviceConfig, cfg, err := loadConfig(os.Args[0], os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(1)
 	}
 
-	// Initialize TUF client
-	repoPath := os.Getenv("TUF_REPO_PATH")
-	if repoPath == "" {
-		repoPath = DefaultRepoPath
+	logger, err := logging.New(serviceConfig.Logging, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid logging configuration: %v\n", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+	allowedSampler = logging.NewSampler(serviceConfig.Logging.SampleAllowed)
+
+	// Install tracing before the first metadata load so it is traced
+	shutdownTracing, err := tracing.Setup(serviceConfig.TraceConfig())
+	if err != nil {
+		fatal("Failed to set up tracing", err)
 	}
+	headers = serviceConfig.Headers
 
-	var err error
-	tufClient, err = tuf.NewClient(tuf.Config{RepoPath: repoPath})
+	tufRefresher, err = tuf.NewRefresher(cfg)
 	if err != nil {
-		log.Fatalf("Failed to initialize TUF client: %v", err)
+		fatal("Failed to initialize TUF client", err)
+	}
+	tufClient := tufRefresher.Client()
+	serviceMetrics = metrics.New(tufRefresher)
+
+	source := cfg.RepoPath
+	if cfg.MetadataURL != "" {
+		source = cfg.MetadataURL
+	}
+	slog.Info("TUF client initialized",
+		"repository", source,
+		"root_version", tufClient.GetRootInfo().Version,
+		"expiry_policy", cfg.ExpiryPolicy.String(),
+		"failure_policy", cfg.FailurePolicy.String())
+	logLoadErrors(tufClient)
+	if cfg.StateDir == "" {
+		slog.Warn("No state directory configured, rollback protection does not persist across restarts",
+			"setting", "TUF_STATE_DIR")
 	}
 
-	log.Printf("TUF client initialized with repository: %s", repoPath)
+	// Background reloads stop once the server has shut down
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Reload metadata in the background; a failed reload keeps the current
+	// verified state
+	go tufRefresher.Run(ctx, logRefresh)
+	if cfg.RefreshInterval > 0 {
+		slog.Info("TUF metadata refresh scheduled",
+			"interval", cfg.RefreshInterval.String(), "jitter", cfg.RefreshJitter)
+	}
+
+	// Reload as soon as the repository directory changes
+	activeWatchMode, err := tufRefresher.StartWatch(ctx, logRefresh)
+	if err != nil {
+		fatal("Failed to watch TUF repository", err)
+	}
+	if activeWatchMode != tuf.WatchOff {
+		slog.Info("Watching TUF repository for changes",
+			"repository", cfg.RepoPath, "mode", activeWatchMode.String())
+	}
+
+	// Reload on SIGHUP
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			slog.Info("SIGHUP received, reloading TUF metadata")
+			logRefresh(tufRefresher.Refresh())
+		}
+	}()
 
 	// Set up routes
-	http.HandleFunc("/auth", authHandler)
-	http.HandleFunc("/health", healthHandler)
-	http.HandleFunc("/debug", debugHandler)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth", authHandler)
+	mux.HandleFunc("/health", healthHandler)
+	mux.HandleFunc("/ready", readyHandler)
+	mux.HandleFunc("/debug", debugHandler)
+	mux.Handle("/metrics", serviceMetrics.Handler())
 
 	// Root handler for basic info
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/" {
 			http.NotFound(w, r)
 			return
@@ -129,12 +463,24 @@ func main() {
 		w.Write([]byte("TUF Client Verify Service - Phase 2 with TUF"))
 	})
 
-	log.Printf("TUF Client Verify service starting on port %s", port)
-	log.Printf("Auth endpoint: http://localhost:%s/auth", port)
-	log.Printf("Health endpoint: http://localhost:%s/health", port)
-	log.Printf("Debug endpoint: http://localhost:%s/debug", port)
+	listener, err := net.Listen("tcp", serviceConfig.Listen.Address)
+	if err != nil {
+		fatal("Failed to listen", err)
+	}
+	slog.Info("TUF Client Verify service starting", "address", listener.Addr().String(),
+		"endpoints", []string{"/auth", "/health", "/ready", "/debug", "/metrics"})
+
+	serveErr := serve(newServer(serviceConfig.Listen, mux), listener, serviceConfig.Listen)
+	cancel()
+
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), traceFlushTimeout)
+	defer flushCancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Warn("Failed to flush traces", "error", err)
+	}
 
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	if serveErr != nil {
+		fatal("Server stopped", serveErr)
 	}
+	slog.Info("Shutdown complete")
 }
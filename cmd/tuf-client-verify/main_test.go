@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matglas/tuf-client-verify/internal/tuf"
+)
+
+func TestRequestIDFor(t *testing.T) {
+	tests := []struct {
+		name  string
+		id    string
+		keeps bool
+	}{
+		{name: "valid", id: "5f2b-a.9_Z", keeps: true},
+		{name: "longest", id: strings.Repeat("a", maxRequestIDLength), keeps: true},
+		{name: "missing", id: ""},
+		{name: "too long", id: strings.Repeat("a", maxRequestIDLength+1)},
+		{name: "space", id: "5f2b a"},
+		{name: "log injection", id: "5f2b\nlevel=ERROR"},
+		{name: "non-ascii", id: "5f2bé"},
+	}
+
+	previous := headers.RequestID
+	headers.RequestID = "X-Request-ID"
+	defer func() { headers.RequestID = previous }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/auth", nil)
+			r.Header.Set(headers.RequestID, tt.id)
+
+			got := requestIDFor(r)
+			if (got == tt.id) != tt.keeps {
+				t.Errorf("requestIDFor(%q) = %q, want the ID kept: %v", tt.id, got, tt.keeps)
+			}
+			if !validRequestID(got) {
+				t.Errorf("requestIDFor(%q) = %q, which is not a valid request ID", tt.id, got)
+			}
+		})
+	}
+}
+
+func TestAuthKeysCacheByOriginalHost(t *testing.T) {
+	startTestRefresher(t, tuf.Config{DecisionCacheSize: 10, DecisionCacheKeyHost: true})
+
+	previous := headers
+	headers.OriginalURI = "X-Original-URI"
+	headers.OriginalHost = "X-Forwarded-Host"
+	defer func() { headers = previous }()
+
+	// Behind auth_request every request reaches the service with the Host
+	// of its upstream
+	for _, originalHost := range []string{"registry-a", "registry-b", "registry-a"} {
+		r := httptest.NewRequest(http.MethodGet, "http://auth_service/auth", nil)
+		r.Header.Set("X-Original-URI", "/v2/library/alpine/manifests/latest")
+		r.Header.Set("X-Forwarded-Host", originalHost)
+
+		w := httptest.NewRecorder()
+		authHandler(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("authHandler() status = %d, want %d", w.Code, http.StatusOK)
+		}
+	}
+
+	if stats := tufRefresher.CacheStats(); stats.Misses != 2 || stats.Hits != 1 {
+		t.Errorf("CacheStats() = %+v, want 2 misses and 1 hit", stats)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  tuf.FailurePolicy
+		fail    bool
+		advance time.Duration
+		code    int
+		body    string
+	}{
+		{name: "healthy", code: http.StatusOK, body: "healthy"},
+		{name: "degraded", fail: true, code: http.StatusOK, body: "degraded: serving last verified metadata until "},
+		{name: "fail closed", policy: tuf.FailClosed, fail: true, code: http.StatusServiceUnavailable, body: "unhealthy: "},
+		{name: "expired", advance: 48 * time.Hour, code: http.StatusServiceUnavailable, body: "unhealthy: "},
+		{name: "degraded and expired", fail: true, advance: 48 * time.Hour, code: http.StatusServiceUnavailable, body: "unhealthy: "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			repoDir := startTestRefresher(t, tuf.Config{
+				FailurePolicy: tt.policy,
+				Clock:         func() time.Time { return now },
+			})
+
+			if tt.fail {
+				if err := os.Remove(filepath.Join(repoDir, "timestamp.json")); err != nil {
+					t.Fatalf("failed to remove timestamp: %v", err)
+				}
+				for i := 0; i < 2; i++ {
+					if _, err := tufRefresher.Refresh(); err == nil {
+						t.Fatal("Refresh() error = nil, want an error")
+					}
+				}
+			}
+			now = now.Add(tt.advance)
+
+			w := httptest.NewRecorder()
+			healthHandler(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+			if w.Code != tt.code || !strings.HasPrefix(w.Body.String(), tt.body) {
+				t.Errorf("/health = %d %q, want %d %q...", w.Code, w.Body.String(), tt.code, tt.body)
+			}
+			if tt.code == http.StatusOK && tt.fail && !strings.HasSuffix(w.Body.String(), "after 2 failed refreshes") {
+				t.Errorf("/health = %q, want the consecutive failure count", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestDebug(t *testing.T) {
+	startTestRefresher(t, tuf.Config{})
+
+	w := httptest.NewRecorder()
+	debugHandler(w, httptest.NewRequest(http.MethodGet, "/debug", nil))
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("/debug = %d with content type %q, want %d JSON", w.Code, w.Header().Get("Content-Type"), http.StatusOK)
+	}
+	var response debugResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode /debug response: %v", err)
+	}
+	if !slices.Contains(response.AllowedPaths, "/v2/library/alpine/manifests/latest") {
+		t.Errorf("/debug allowed paths = %v, want /v2/library/alpine/manifests/latest", response.AllowedPaths)
+	}
+	if len(response.Delegations["registry-library"]) == 0 {
+		t.Errorf("/debug delegations = %v, want the registry-library patterns", response.Delegations)
+	}
+	if response.RootVersion != tufRefresher.Client().GetRootInfo().Version {
+		t.Errorf("/debug root version = %d, want %d", response.RootVersion, tufRefresher.Client().GetRootInfo().Version)
+	}
+	if response.Roles["registry-library"].State != tuf.RoleLoaded {
+		t.Errorf("/debug registry-library role = %+v, want loaded", response.Roles["registry-library"])
+	}
+	if _, ok := response.TopLevelRoles["targets"]; !ok {
+		t.Errorf("/debug top-level roles = %v, want targets", response.TopLevelRoles)
+	}
+
+	w = httptest.NewRecorder()
+	debugHandler(w, httptest.NewRequest(http.MethodGet, "/debug?path=/v2/library/alpine/manifests/latest", nil))
+
+	var decision tuf.Decision
+	if err := json.NewDecoder(w.Body).Decode(&decision); err != nil {
+		t.Fatalf("failed to decode /debug?path= response: %v", err)
+	}
+	if !decision.Allowed {
+		t.Errorf("/debug?path= decision denied with reason %q, want allowed", decision.Reason)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/matglas/tuf-client-verify/internal/config"
+)
+
+// shuttingDown is set once a shutdown signal is received, so readiness fails
+// before the server stops accepting connections
+var shuttingDown atomic.Bool
+
+// readyHandler reports whether the service should receive auth requests. It
+// fails once shutdown begins and while no path can be authorized.
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	if shuttingDown.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("not ready: shutting down"))
+		return
+	}
+
+	if !tufRefresher.Status().Authorizing {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("not ready: no verified metadata to authorize from"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ready"))
+}
+
+// newServer returns an HTTP server for handler with the configured timeouts
+// and header size limit
+func newServer(cfg config.ListenConfig, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Address,
+		Handler:           handler,
+		ReadHeaderTimeout: time.Duration(cfg.ReadHeaderTimeout),
+		ReadTimeout:       time.Duration(cfg.ReadTimeout),
+		WriteTimeout:      time.Duration(cfg.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.IdleTimeout),
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
+
+// serve serves on listener until SIGTERM or SIGINT, then shuts down as
+// described for serveUntil
+func serve(server *http.Server, listener net.Listener, cfg config.ListenConfig) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(signals)
+
+	return serveUntil(server, listener, cfg, signals)
+}
+
+// serveUntil serves on listener until a signal arrives on signals. It then
+// reports not ready for ShutdownDelay while still serving, stops accepting
+// connections and waits up to ShutdownTimeout for in-flight requests to
+// complete. A second signal skips the delay, and a third abandons the
+// requests still in flight.
+func serveUntil(server *http.Server, listener net.Listener, cfg config.ListenConfig, signals <-chan os.Signal) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("failed to serve: %w", err)
+	case sig := <-signals:
+		slog.Info("Shutdown signal received, reporting not ready",
+			"signal", sig.String(), "delay", time.Duration(cfg.ShutdownDelay).String())
+	}
+
+	// Keep serving while load balancers observe the failing readiness check,
+	// closing connections after each response so clients reconnect elsewhere
+	shuttingDown.Store(true)
+	server.SetKeepAlivesEnabled(false)
+	select {
+	case <-time.After(time.Duration(cfg.ShutdownDelay)):
+	case <-signals:
+	}
+
+	slog.Info("Draining in-flight requests", "timeout", time.Duration(cfg.ShutdownTimeout).String())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout))
+	defer cancel()
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := server.Shutdown(ctx); err != nil {
+		server.Close()
+		return fmt.Errorf("failed to drain in-flight requests: %w", err)
+	}
+
+	return nil
+}
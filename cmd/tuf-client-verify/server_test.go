@@ -0,0 +1,188 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/matglas/tuf-client-verify/internal/config"
+)
+
+// testServer is a server run by serveUntil whose /slow requests block until
+// released
+type testServer struct {
+	t       *testing.T
+	url     string
+	signals chan os.Signal
+	started chan struct{}
+	release chan struct{}
+	done    chan error
+}
+
+// startTestServer runs serveUntil on a local port with the given shutdown
+// delay and timeout. Signals are delivered unbuffered, so each send returns
+// once the shutdown step waiting for it has received it.
+func startTestServer(t *testing.T, delay, timeout time.Duration) *testServer {
+	t.Helper()
+
+	s := &testServer{
+		t:       t,
+		signals: make(chan os.Signal),
+		started: make(chan struct{}, 1),
+		release: make(chan struct{}),
+		done:    make(chan error, 1),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ready", readyHandler)
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		s.started <- struct{}{}
+		<-s.release
+		w.Write([]byte("done"))
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s.url = "http://" + listener.Addr().String()
+
+	cfg := config.ListenConfig{
+		ShutdownDelay:   config.Duration(delay),
+		ShutdownTimeout: config.Duration(timeout),
+	}
+	go func() {
+		s.done <- serveUntil(newServer(cfg, mux), listener, cfg, s.signals)
+	}()
+
+	t.Cleanup(func() {
+		select {
+		case <-s.release:
+		default:
+			close(s.release)
+		}
+		shuttingDown.Store(false)
+	})
+
+	return s
+}
+
+// get requests path on a new connection and returns the status and body
+func (s *testServer) get(path string) (int, string, error) {
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	resp, err := client.Get(s.url + path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body), err
+}
+
+// startSlowRequest sends a /slow request and waits until the server is
+// handling it. The returned channel receives the body once it completes.
+func (s *testServer) startSlowRequest() <-chan string {
+	s.t.Helper()
+
+	result := make(chan string, 1)
+	go func() {
+		_, body, err := s.get("/slow")
+		if err != nil {
+			body = err.Error()
+		}
+		result <- body
+	}()
+
+	select {
+	case <-s.started:
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("slow request was not received")
+	}
+
+	return result
+}
+
+// waitClosed waits until the server refuses new connections
+func (s *testServer) waitClosed() {
+	s.t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, _, err := s.get("/ready"); err != nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.t.Fatal("server still accepts connections")
+}
+
+// wait returns the error serveUntil returned
+func (s *testServer) wait() error {
+	s.t.Helper()
+
+	select {
+	case err := <-s.done:
+		return err
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("serveUntil did not return")
+		return nil
+	}
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	s := startTestServer(t, time.Minute, time.Minute)
+	slow := s.startSlowRequest()
+
+	// The first signal fails readiness while the server keeps serving
+	s.signals <- syscall.SIGTERM
+	if status, body, err := s.get("/ready"); err != nil || status != http.StatusServiceUnavailable {
+		t.Fatalf("GET /ready = %d %q, %v during the shutdown delay, want %d", status, body, err, http.StatusServiceUnavailable)
+	}
+
+	// The second signal skips the delay and starts draining
+	s.signals <- syscall.SIGTERM
+	s.waitClosed()
+
+	close(s.release)
+	if body := <-slow; body != "done" {
+		t.Errorf("in-flight request returned %q, want it to complete", body)
+	}
+	if err := s.wait(); err != nil {
+		t.Errorf("serveUntil() error = %v", err)
+	}
+}
+
+func TestServeShutsDownAfterDelay(t *testing.T) {
+	s := startTestServer(t, 50*time.Millisecond, time.Minute)
+
+	s.signals <- os.Interrupt
+	if err := s.wait(); err != nil {
+		t.Errorf("serveUntil() error = %v", err)
+	}
+	if !shuttingDown.Load() {
+		t.Error("shuttingDown not set after shutdown")
+	}
+}
+
+func TestServeThirdSignalAbandonsDrain(t *testing.T) {
+	s := startTestServer(t, time.Minute, time.Minute)
+	slow := s.startSlowRequest()
+
+	s.signals <- syscall.SIGTERM
+	s.signals <- syscall.SIGTERM
+	s.waitClosed()
+	s.signals <- syscall.SIGTERM
+
+	if err := s.wait(); err == nil || !strings.Contains(err.Error(), "failed to drain in-flight requests") {
+		t.Errorf("serveUntil() error = %v, want the drain abandoned", err)
+	}
+	if body := <-slow; body == "done" {
+		t.Error("in-flight request completed after the drain was abandoned")
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/matglas/tuf-client-verify/internal/logging"
+	"github.com/matglas/tuf-client-verify/internal/metrics"
+	"github.com/matglas/tuf-client-verify/internal/tracing"
+	"github.com/matglas/tuf-client-verify/internal/tuf"
+	"github.com/matglas/tuf-client-verify/internal/tuftest"
+)
+
+// startTestRefresher serves a generated repository and sets up the globals
+// authHandler uses, with a refresher configured by cfg. It returns the
+// directory the repository is served from.
+func startTestRefresher(t *testing.T, cfg tuf.Config) string {
+	t.Helper()
+
+	repoDir, rootBytes := tuftest.GenerateRepository(t)
+	cfg.MetadataURL = tuftest.ServeRepository(t, repoDir)
+	cfg.RootBytes = rootBytes
+	refresher, err := tuf.NewRefresher(cfg)
+	if err != nil {
+		t.Fatalf("NewRefresher() error = %v", err)
+	}
+
+	previousRefresher, previousMetrics, previousSampler := tufRefresher, serviceMetrics, allowedSampler
+	tufRefresher = refresher
+	serviceMetrics = metrics.New(refresher)
+	allowedSampler = logging.NewSampler(0)
+	t.Cleanup(func() {
+		tufRefresher, serviceMetrics, allowedSampler = previousRefresher, previousMetrics, previousSampler
+	})
+
+	return repoDir
+}
+
+var (
+	testProviderOnce sync.Once
+	testProvider     *sdktrace.TracerProvider
+	testExporter     *tracetest.InMemoryExporter
+)
+
+// installTestProvider installs trace context propagation and a global tracer
+// provider recording spans in memory. Tracers delegate to the first global
+// provider installed, so it is installed once and shared by all tests.
+func installTestProvider(t *testing.T) (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	t.Helper()
+
+	testProviderOnce.Do(func() {
+		if _, err := tracing.Setup(tracing.Config{Exporter: tracing.ExporterNone}); err != nil {
+			t.Fatalf("Setup() error = %v", err)
+		}
+		testExporter = tracetest.NewInMemoryExporter()
+		testProvider = tracing.NewProvider(testExporter, 0)
+		otel.SetTracerProvider(testProvider)
+	})
+
+	return testProvider, testExporter
+}
+
+func TestAuthContinuesIncomingTrace(t *testing.T) {
+	startTestRefresher(t, tuf.Config{})
+
+	provider, exporter := installTestProvider(t)
+
+	const traceID, parentID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+
+	tests := []struct {
+		name    string
+		uri     string
+		status  int
+		indexed bool
+	}{
+		{name: "index hit", uri: "/v2/library/alpine/manifests/latest", status: http.StatusOK, indexed: true},
+		{name: "delegation search", uri: "/v2/library/busybox/manifests/latest", status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter.Reset()
+
+			r := httptest.NewRequest(http.MethodGet, "/auth", nil)
+			r.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+			r.Header.Set("X-Original-URI", tt.uri)
+			headers.OriginalURI = "X-Original-URI"
+			defer func() { headers.OriginalURI = "" }()
+
+			w := httptest.NewRecorder()
+			authHandler(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("authHandler() status = %d, want %d", w.Code, tt.status)
+			}
+			if err := provider.ForceFlush(context.Background()); err != nil {
+				t.Fatalf("ForceFlush() error = %v", err)
+			}
+
+			spans := make(map[string][]tracetest.SpanStub)
+			for _, span := range exporter.GetSpans() {
+				if span.SpanContext.TraceID().String() != traceID {
+					t.Errorf("span %s has trace %s, want %s", span.Name, span.SpanContext.TraceID(), traceID)
+				}
+				spans[span.Name] = append(spans[span.Name], span)
+			}
+			if len(spans["auth"]) != 1 || len(spans["tuf.Decide"]) != 1 {
+				t.Fatalf("recorded spans %v, want one auth and one tuf.Decide span", spanNames(spans))
+			}
+
+			auth, decide := spans["auth"][0], spans["tuf.Decide"][0]
+			if got := auth.Parent.SpanID().String(); got != parentID || !auth.Parent.IsRemote() {
+				t.Errorf("auth parent = %s, want remote span %s", got, parentID)
+			}
+			if decide.Parent.SpanID() != auth.SpanContext.SpanID() {
+				t.Errorf("tuf.Decide parent = %s, want auth span %s", decide.Parent.SpanID(), auth.SpanContext.SpanID())
+			}
+			if got := boolAttribute(decide.Attributes, "tuf.indexed"); got != tt.indexed {
+				t.Errorf("tuf.Decide tuf.indexed = %v, want %v", got, tt.indexed)
+			}
+
+			// Index hits skip the delegation search
+			consulted := spans["tuf.Consult"]
+			if tt.indexed && len(consulted) > 0 {
+				t.Errorf("recorded %d tuf.Consult spans for an index hit, want none", len(consulted))
+			}
+			if !tt.indexed && len(consulted) == 0 {
+				t.Error("recorded no tuf.Consult spans for a delegation search")
+			}
+			for _, span := range consulted {
+				if span.Parent.SpanID() != decide.SpanContext.SpanID() {
+					t.Errorf("tuf.Consult parent = %s, want tuf.Decide span %s", span.Parent.SpanID(), decide.SpanContext.SpanID())
+				}
+			}
+		})
+	}
+}
+
+// spanNames returns the number of spans recorded under each name
+func spanNames(spans map[string][]tracetest.SpanStub) map[string]int {
+	names := make(map[string]int, len(spans))
+	for name, named := range spans {
+		names[name] = len(named)
+	}
+
+	return names
+}
+
+// boolAttribute returns the boolean attribute key of attributes, or false if
+// it is not set
+func boolAttribute(attributes []attribute.KeyValue, key attribute.Key) bool {
+	for _, kv := range attributes {
+		if kv.Key == key {
+			return kv.Value.AsBool()
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,429 @@
+// Package config loads the service configuration from defaults, an optional
+// YAML or JSON file, environment variables and command line flags, in that
+// order of precedence.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/matglas/tuf-client-verify/internal/tracing"
+	"github.com/matglas/tuf-client-verify/internal/tuf"
+)
+
+const (
+	DefaultListenAddress        = ":8080"
+	DefaultRepoPath             = "testdata/repository"
+	DefaultReadHeaderTimeout    = 5 * time.Second
+	DefaultReadTimeout          = 10 * time.Second
+	DefaultWriteTimeout         = 10 * time.Second
+	DefaultIdleTimeout          = 2 * time.Minute
+	DefaultMaxHeaderBytes       = 64 << 10
+	DefaultShutdownDelay        = 5 * time.Second
+	DefaultShutdownTimeout      = 20 * time.Second
+	DefaultRefreshInterval      = 5 * time.Minute
+	DefaultRefreshJitter        = 0.1
+	DefaultDecisionCacheSize    = 10000
+	DefaultOriginalURIHeader    = "X-Original-URI"
+	DefaultOriginalMethodHeader = "X-Original-Method"
+	DefaultOriginalHostHeader   = "X-Forwarded-Host"
+	DefaultRequestIDHeader      = "X-Request-ID"
+	DefaultClientIPHeader       = "X-Real-IP"
+	DefaultLogLevel             = "info"
+	DefaultLogFormat            = "text"
+)
+
+// Config is the complete service configuration
+type Config struct {
+	Listen     ListenConfig     `yaml:"listen" json:"listen"`
+	Repository RepositoryConfig `yaml:"repository" json:"repository"`
+	Refresh    RefreshConfig    `yaml:"refresh" json:"refresh"`
+	Cache      CacheConfig      `yaml:"cache" json:"cache"`
+	Headers    HeadersConfig    `yaml:"headers" json:"headers"`
+	Logging    LoggingConfig    `yaml:"logging" json:"logging"`
+	Tracing    TracingConfig    `yaml:"tracing" json:"tracing"`
+}
+
+// ListenConfig configures the HTTP listener and server
+type ListenConfig struct {
+	// Address is the host:port the service listens on
+	Address string `yaml:"address" json:"address"`
+
+	// ReadHeaderTimeout, ReadTimeout, WriteTimeout and IdleTimeout bound
+	// how long a connection may take to send its request headers, read the
+	// whole request, write the response and wait for its next request
+	ReadHeaderTimeout Duration `yaml:"read_header_timeout" json:"read_header_timeout"`
+	ReadTimeout       Duration `yaml:"read_timeout" json:"read_timeout"`
+	WriteTimeout      Duration `yaml:"write_timeout" json:"write_timeout"`
+	IdleTimeout       Duration `yaml:"idle_timeout" json:"idle_timeout"`
+
+	// MaxHeaderBytes limits the size of request headers
+	MaxHeaderBytes int `yaml:"max_header_bytes" json:"max_header_bytes"`
+
+	// ShutdownDelay is how long the service reports not ready before it
+	// stops accepting connections, so load balancers stop sending requests
+	ShutdownDelay Duration `yaml:"shutdown_delay" json:"shutdown_delay"`
+
+	// ShutdownTimeout is how long in-flight requests may take to complete
+	// once the service stops accepting connections
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" json:"shutdown_timeout"`
+}
+
+// RepositoryConfig configures where TUF metadata is loaded from and how it
+// is trusted
+type RepositoryConfig struct {
+	Path              string      `yaml:"path" json:"path"`
+	MetadataURL       string      `yaml:"metadata_url" json:"metadata_url"`
+	TargetsURL        string      `yaml:"targets_url" json:"targets_url"`
+	CacheDir          string      `yaml:"cache_dir" json:"cache_dir"`
+	StateDir          string      `yaml:"state_dir" json:"state_dir"`
+	ExpiryPolicy      string      `yaml:"expiry_policy" json:"expiry_policy"`
+	StrictDelegations bool        `yaml:"strict_delegations" json:"strict_delegations"`
+	Watch             WatchConfig `yaml:"watch" json:"watch"`
+}
+
+// WatchConfig configures reloading when the local repository changes
+type WatchConfig struct {
+	Mode         string   `yaml:"mode" json:"mode"`
+	Debounce     Duration `yaml:"debounce" json:"debounce"`
+	PollInterval Duration `yaml:"poll_interval" json:"poll_interval"`
+}
+
+// RefreshConfig configures periodic metadata reloads and what happens when
+// they fail
+type RefreshConfig struct {
+	Interval      Duration `yaml:"interval" json:"interval"`
+	Jitter        float64  `yaml:"jitter" json:"jitter"`
+	FailurePolicy string   `yaml:"failure_policy" json:"failure_policy"`
+}
+
+// CacheConfig configures the decision cache
+type CacheConfig struct {
+	Size int      `yaml:"size" json:"size"`
+	TTL  Duration `yaml:"ttl" json:"ttl"`
+
+	// KeyMethod and KeyHost add the original request method and host to the
+	// cache key
+	KeyMethod bool `yaml:"key_method" json:"key_method"`
+	KeyHost   bool `yaml:"key_host" json:"key_host"`
+}
+
+// HeadersConfig maps the request headers set by the proxy
+type HeadersConfig struct {
+	// OriginalURI carries the path of the request being authorized
+	OriginalURI string `yaml:"original_uri" json:"original_uri"`
+
+	// OriginalMethod carries the method of the request being authorized
+	OriginalMethod string `yaml:"original_method" json:"original_method"`
+
+	// OriginalHost carries the host of the request being authorized. The
+	// Host of an auth subrequest names the auth service's upstream instead.
+	OriginalHost string `yaml:"original_host" json:"original_host"`
+
+	// RequestID carries the request ID used to correlate log records. It is
+	// echoed in the response, with a generated ID if the request had none.
+	RequestID string `yaml:"request_id" json:"request_id"`
+
+	// ClientIP carries the address of the client of the original request
+	ClientIP string `yaml:"client_ip" json:"client_ip"`
+}
+
+// LoggingConfig configures log output
+type LoggingConfig struct {
+	// Level is one of debug, info, warn or error
+	Level string `yaml:"level" json:"level"`
+
+	// Format is text or json
+	Format string `yaml:"format" json:"format"`
+
+	// SampleAllowed logs one in every SampleAllowed allowed decisions; 1
+	// logs all of them and 0 none. Denials are always logged.
+	SampleAllowed int `yaml:"sample_allowed" json:"sample_allowed"`
+}
+
+// TracingConfig configures span export
+type TracingConfig struct {
+	// Exporter is none, stdout, file or another registered exporter
+	Exporter string `yaml:"exporter" json:"exporter"`
+
+	// File is the path the file exporter appends spans to
+	File string `yaml:"file" json:"file"`
+
+	// SampleRatio is the fraction of traces sampled when the proxy did not
+	// propagate a sampling decision
+	SampleRatio float64 `yaml:"sample_ratio" json:"sample_ratio"`
+}
+
+// Duration is a time.Duration written as a Go duration string such as "5m"
+// in config files, environment variables and flags
+type Duration time.Duration
+
+// String formats d as a Go duration string
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Set parses a Go duration string into d
+func (d *Duration) Set(value string) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText formats d as a Go duration string
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText parses a Go duration string into d
+func (d *Duration) UnmarshalText(text []byte) error {
+	return d.Set(string(text))
+}
+
+// Default returns the configuration used when nothing is overridden
+func Default() *Config {
+	return &Config{
+		Listen: ListenConfig{
+			Address:           DefaultListenAddress,
+			ReadHeaderTimeout: Duration(DefaultReadHeaderTimeout),
+			ReadTimeout:       Duration(DefaultReadTimeout),
+			WriteTimeout:      Duration(DefaultWriteTimeout),
+			IdleTimeout:       Duration(DefaultIdleTimeout),
+			MaxHeaderBytes:    DefaultMaxHeaderBytes,
+			ShutdownDelay:     Duration(DefaultShutdownDelay),
+			ShutdownTimeout:   Duration(DefaultShutdownTimeout),
+		},
+		Repository: RepositoryConfig{
+			Path:         DefaultRepoPath,
+			ExpiryPolicy: tuf.ExpiryPolicyDeny.String(),
+			Watch: WatchConfig{
+				Mode:         tuf.WatchAuto.String(),
+				Debounce:     Duration(tuf.DefaultReloadDebounce),
+				PollInterval: Duration(tuf.DefaultPollInterval),
+			},
+		},
+		Refresh: RefreshConfig{
+			Interval:      Duration(DefaultRefreshInterval),
+			Jitter:        DefaultRefreshJitter,
+			FailurePolicy: tuf.FailOpenUntilExpiry.String(),
+		},
+		Cache: CacheConfig{
+			Size: DefaultDecisionCacheSize,
+		},
+		Headers: HeadersConfig{
+			OriginalURI:    DefaultOriginalURIHeader,
+			OriginalMethod: DefaultOriginalMethodHeader,
+			OriginalHost:   DefaultOriginalHostHeader,
+			RequestID:      DefaultRequestIDHeader,
+			ClientIP:       DefaultClientIPHeader,
+		},
+		Logging: LoggingConfig{
+			Level:         DefaultLogLevel,
+			Format:        DefaultLogFormat,
+			SampleAllowed: 1,
+		},
+		Tracing: TracingConfig{
+			Exporter:    tracing.ExporterNone,
+			SampleRatio: 1,
+		},
+	}
+}
+
+// setting binds a configuration field to a command line flag and an
+// environment variable
+type setting struct {
+	flag string
+	env  string
+}
+
+// settings lists the flag and environment variable of every field
+var settings = []setting{
+	{"listen", "TUF_LISTEN_ADDRESS"},
+	{"read-header-timeout", "TUF_READ_HEADER_TIMEOUT"},
+	{"read-timeout", "TUF_READ_TIMEOUT"},
+	{"write-timeout", "TUF_WRITE_TIMEOUT"},
+	{"idle-timeout", "TUF_IDLE_TIMEOUT"},
+	{"max-header-bytes", "TUF_MAX_HEADER_BYTES"},
+	{"shutdown-delay", "TUF_SHUTDOWN_DELAY"},
+	{"shutdown-timeout", "TUF_SHUTDOWN_TIMEOUT"},
+	{"repo-path", "TUF_REPO_PATH"},
+	{"metadata-url", "TUF_METADATA_URL"},
+	{"targets-url", "TUF_TARGETS_URL"},
+	{"cache-dir", "TUF_CACHE_DIR"},
+	{"state-dir", "TUF_STATE_DIR"},
+	{"expiry-policy", "TUF_EXPIRY_POLICY"},
+	{"strict-delegations", "TUF_STRICT_DELEGATIONS"},
+	{"watch-mode", "TUF_WATCH_MODE"},
+	{"reload-debounce", "TUF_RELOAD_DEBOUNCE"},
+	{"poll-interval", "TUF_POLL_INTERVAL"},
+	{"refresh-interval", "TUF_REFRESH_INTERVAL"},
+	{"refresh-jitter", "TUF_REFRESH_JITTER"},
+	{"failure-policy", "TUF_FAILURE_POLICY"},
+	{"decision-cache-size", "TUF_DECISION_CACHE_SIZE"},
+	{"decision-cache-ttl", "TUF_DECISION_CACHE_TTL"},
+	{"decision-cache-key-method", "TUF_DECISION_CACHE_KEY_METHOD"},
+	{"decision-cache-key-host", "TUF_DECISION_CACHE_KEY_HOST"},
+	{"original-uri-header", "TUF_ORIGINAL_URI_HEADER"},
+	{"original-method-header", "TUF_ORIGINAL_METHOD_HEADER"},
+	{"original-host-header", "TUF_ORIGINAL_HOST_HEADER"},
+	{"request-id-header", "TUF_REQUEST_ID_HEADER"},
+	{"client-ip-header", "TUF_CLIENT_IP_HEADER"},
+	{"log-level", "TUF_LOG_LEVEL"},
+	{"log-format", "TUF_LOG_FORMAT"},
+	{"log-sample-allowed", "TUF_LOG_SAMPLE_ALLOWED"},
+	{"trace-exporter", "TUF_TRACE_EXPORTER"},
+	{"trace-file", "TUF_TRACE_FILE"},
+	{"trace-sample-ratio", "TUF_TRACE_SAMPLE_RATIO"},
+}
+
+// flagSet returns a flag set whose flags write directly into c, plus the
+// flag naming the config file
+func (c *Config) flagSet(name string, configFile *string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+
+	fs.StringVar(configFile, "config", "", "path to a YAML or JSON config file (env TUF_CONFIG_FILE)")
+
+	fs.StringVar(&c.Listen.Address, "listen", c.Listen.Address, "address to listen on")
+	fs.Var(&c.Listen.ReadHeaderTimeout, "read-header-timeout", "time allowed to read request headers")
+	fs.Var(&c.Listen.ReadTimeout, "read-timeout", "time allowed to read a request")
+	fs.Var(&c.Listen.WriteTimeout, "write-timeout", "time allowed to write a response")
+	fs.Var(&c.Listen.IdleTimeout, "idle-timeout", "time an idle keep-alive connection is kept open")
+	fs.IntVar(&c.Listen.MaxHeaderBytes, "max-header-bytes", c.Listen.MaxHeaderBytes, "maximum size of request headers")
+	fs.Var(&c.Listen.ShutdownDelay, "shutdown-delay", "time to report not ready before draining on shutdown")
+	fs.Var(&c.Listen.ShutdownTimeout, "shutdown-timeout", "time allowed for in-flight requests to complete on shutdown")
+	fs.StringVar(&c.Repository.Path, "repo-path", c.Repository.Path, "local TUF repository directory")
+	fs.StringVar(&c.Repository.MetadataURL, "metadata-url", c.Repository.MetadataURL, "remote TUF metadata URL")
+	fs.StringVar(&c.Repository.TargetsURL, "targets-url", c.Repository.TargetsURL, "remote TUF targets URL")
+	fs.StringVar(&c.Repository.CacheDir, "cache-dir", c.Repository.CacheDir, "cache directory for remote metadata")
+	fs.StringVar(&c.Repository.StateDir, "state-dir", c.Repository.StateDir, "directory for persisted trusted state")
+	fs.StringVar(&c.Repository.ExpiryPolicy, "expiry-policy", c.Repository.ExpiryPolicy, "deny or allow-expired")
+	fs.BoolVar(&c.Repository.StrictDelegations, "strict-delegations", c.Repository.StrictDelegations, "refuse metadata with missing or invalid delegated roles")
+	fs.StringVar(&c.Repository.Watch.Mode, "watch-mode", c.Repository.Watch.Mode, "auto, notify, poll or off")
+	fs.Var(&c.Repository.Watch.Debounce, "reload-debounce", "quiet period before reloading a changed repository")
+	fs.Var(&c.Repository.Watch.PollInterval, "poll-interval", "repository scan interval in poll mode")
+	fs.Var(&c.Refresh.Interval, "refresh-interval", "metadata refresh interval (0 disables)")
+	fs.Float64Var(&c.Refresh.Jitter, "refresh-jitter", c.Refresh.Jitter, "fraction of the refresh interval to jitter by")
+	fs.StringVar(&c.Refresh.FailurePolicy, "failure-policy", c.Refresh.FailurePolicy, "fail-open-until-expiry or fail-closed")
+	fs.IntVar(&c.Cache.Size, "decision-cache-size", c.Cache.Size, "number of cached decisions (0 disables)")
+	fs.Var(&c.Cache.TTL, "decision-cache-ttl", "upper bound on how long a decision is cached")
+	fs.BoolVar(&c.Cache.KeyMethod, "decision-cache-key-method", c.Cache.KeyMethod, "key cached decisions by request method")
+	fs.BoolVar(&c.Cache.KeyHost, "decision-cache-key-host", c.Cache.KeyHost, "key cached decisions by original request host")
+	fs.StringVar(&c.Headers.OriginalURI, "original-uri-header", c.Headers.OriginalURI, "header carrying the original request URI")
+	fs.StringVar(&c.Headers.OriginalMethod, "original-method-header", c.Headers.OriginalMethod, "header carrying the original request method")
+	fs.StringVar(&c.Headers.OriginalHost, "original-host-header", c.Headers.OriginalHost, "header carrying the original request host")
+	fs.StringVar(&c.Headers.RequestID, "request-id-header", c.Headers.RequestID, "header carrying the request ID")
+	fs.StringVar(&c.Headers.ClientIP, "client-ip-header", c.Headers.ClientIP, "header carrying the original client address")
+	fs.StringVar(&c.Logging.Level, "log-level", c.Logging.Level, "debug, info, warn or error")
+	fs.StringVar(&c.Logging.Format, "log-format", c.Logging.Format, "text or json")
+	fs.IntVar(&c.Logging.SampleAllowed, "log-sample-allowed", c.Logging.SampleAllowed, "log one in every N allowed decisions (0 logs none)")
+	fs.StringVar(&c.Tracing.Exporter, "trace-exporter", c.Tracing.Exporter, "span exporter: "+strings.Join(append([]string{tracing.ExporterNone}, tracing.Exporters()...), ", "))
+	fs.StringVar(&c.Tracing.File, "trace-file", c.Tracing.File, "file the file span exporter appends to")
+	fs.Float64Var(&c.Tracing.SampleRatio, "trace-sample-ratio", c.Tracing.SampleRatio, "fraction of new traces to sample")
+
+	return fs
+}
+
+// Load builds the configuration from the defaults, the config file named by
+// -config or TUF_CONFIG_FILE, the environment and the flags in args, each
+// overriding the previous. It does not validate the result.
+func Load(name string, args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+
+	var configFile string
+	fs := cfg.flagSet(name, &configFile)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument: %s", fs.Arg(0))
+	}
+
+	// Flags take precedence over the file and the environment, so remember
+	// the ones given and apply them again once those are loaded
+	given := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = f.Value.String()
+	})
+	*cfg = *Default()
+
+	if configFile == "" {
+		configFile, _ = lookupEnv("TUF_CONFIG_FILE")
+	}
+	if configFile != "" {
+		if err := cfg.loadFile(configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.applyEnv(fs, lookupEnv); err != nil {
+		return nil, err
+	}
+
+	for flagName, value := range given {
+		if err := fs.Set(flagName, value); err != nil {
+			return nil, fmt.Errorf("invalid value for -%s: %w", flagName, err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// loadFile decodes the config file at path over c. Files ending in .json are
+// read as JSON and everything else as YAML; unknown keys are rejected.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(c); err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		return nil
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv sets every field whose environment variable is set. PORT is
+// accepted as a shorthand for listening on all interfaces.
+func (c *Config) applyEnv(fs *flag.FlagSet, lookupEnv func(string) (string, bool)) error {
+	if port, ok := lookupEnv("PORT"); ok && port != "" {
+		c.Listen.Address = ":" + port
+	}
+
+	var errs []error
+	for _, s := range settings {
+		value, ok := lookupEnv(s.env)
+		if !ok || value == "" {
+			continue
+		}
+		if err := fs.Set(s.flag, value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s %q: %w", s.env, value, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,257 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// envLookup returns a lookupEnv function reading from env
+func envLookup(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+// writeConfigFile writes content to a file called name in a new directory
+// and returns its path
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := writeConfigFile(t, "config.yaml", "listen:\n  address: :7000\nrefresh:\n  interval: 1m\n")
+	jsonFile := writeConfigFile(t, "config.json", `{"listen": {"address": ":7100"}, "refresh": {"interval": "2m"}}`)
+
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		address  string
+		interval time.Duration
+	}{
+		{
+			name:     "defaults",
+			address:  DefaultListenAddress,
+			interval: DefaultRefreshInterval,
+		},
+		{
+			name:     "yaml file from flag",
+			args:     []string{"-config", yamlFile},
+			address:  ":7000",
+			interval: time.Minute,
+		},
+		{
+			name:     "json file from env",
+			env:      map[string]string{"TUF_CONFIG_FILE": jsonFile},
+			address:  ":7100",
+			interval: 2 * time.Minute,
+		},
+		{
+			name:     "config flag over config env",
+			args:     []string{"-config", yamlFile},
+			env:      map[string]string{"TUF_CONFIG_FILE": jsonFile},
+			address:  ":7000",
+			interval: time.Minute,
+		},
+		{
+			name:     "env over file",
+			args:     []string{"-config", yamlFile},
+			env:      map[string]string{"TUF_LISTEN_ADDRESS": ":7200"},
+			address:  ":7200",
+			interval: time.Minute,
+		},
+		{
+			name:     "PORT over file",
+			args:     []string{"-config", yamlFile},
+			env:      map[string]string{"PORT": "7300"},
+			address:  ":7300",
+			interval: time.Minute,
+		},
+		{
+			name:     "listen address env over PORT",
+			env:      map[string]string{"PORT": "7300", "TUF_LISTEN_ADDRESS": ":7200"},
+			address:  ":7200",
+			interval: DefaultRefreshInterval,
+		},
+		{
+			name:     "empty env ignored",
+			env:      map[string]string{"PORT": "", "TUF_LISTEN_ADDRESS": "", "TUF_REFRESH_INTERVAL": ""},
+			address:  DefaultListenAddress,
+			interval: DefaultRefreshInterval,
+		},
+		{
+			name:     "flags over env and file",
+			args:     []string{"-config", yamlFile, "-listen", ":7400", "-refresh-interval", "3m"},
+			env:      map[string]string{"PORT": "7300", "TUF_LISTEN_ADDRESS": ":7200", "TUF_REFRESH_INTERVAL": "4m"},
+			address:  ":7400",
+			interval: 3 * time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load("test", tt.args, envLookup(tt.env))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if cfg.Listen.Address != tt.address {
+				t.Errorf("Load() listen address = %q, want %q", cfg.Listen.Address, tt.address)
+			}
+			if got := time.Duration(cfg.Refresh.Interval); got != tt.interval {
+				t.Errorf("Load() refresh interval = %v, want %v", got, tt.interval)
+			}
+
+			// Fields nothing overrides keep their defaults
+			if cfg.Headers != Default().Headers {
+				t.Errorf("Load() headers = %+v, want defaults", cfg.Headers)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{
+			name: "unknown yaml key",
+			args: []string{"-config", writeConfigFile(t, "config.yaml", "listen:\n  adress: :7000\n")},
+			want: "field adress not found",
+		},
+		{
+			name: "unknown json key",
+			args: []string{"-config", writeConfigFile(t, "config.json", `{"listen": {"adress": ":7000"}}`)},
+			want: `unknown field "adress"`,
+		},
+		{
+			name: "invalid duration in file",
+			args: []string{"-config", writeConfigFile(t, "config.yaml", "refresh:\n  interval: often\n")},
+			want: "failed to parse config file",
+		},
+		{
+			name: "missing file",
+			args: []string{"-config", filepath.Join(t.TempDir(), "missing.yaml")},
+			want: "failed to read config file",
+		},
+		{
+			name: "invalid env",
+			env:  map[string]string{"TUF_REFRESH_INTERVAL": "often"},
+			want: `invalid TUF_REFRESH_INTERVAL "often"`,
+		},
+		{
+			name: "invalid flag",
+			args: []string{"-max-header-bytes", "many"},
+			want: "invalid value",
+		},
+		{
+			name: "unknown flag",
+			args: []string{"-unknown"},
+			want: "flag provided but not defined",
+		},
+		{
+			name: "argument",
+			args: []string{"extra"},
+			want: "unexpected argument: extra",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load("test", tt.args, envLookup(tt.env))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("Default().Validate() error = %v", err)
+	}
+
+	tests := []struct {
+		key    string
+		modify func(c *Config)
+	}{
+		{"listen.address", func(c *Config) { c.Listen.Address = "localhost" }},
+		{"listen.address", func(c *Config) { c.Listen.Address = ":70000" }},
+		{"listen.read_header_timeout", func(c *Config) { c.Listen.ReadHeaderTimeout = -1 }},
+		{"listen.read_timeout", func(c *Config) { c.Listen.ReadTimeout = -1 }},
+		{"listen.write_timeout", func(c *Config) { c.Listen.WriteTimeout = -1 }},
+		{"listen.idle_timeout", func(c *Config) { c.Listen.IdleTimeout = -1 }},
+		{"listen.shutdown_delay", func(c *Config) { c.Listen.ShutdownDelay = -1 }},
+		{"listen.shutdown_timeout", func(c *Config) { c.Listen.ShutdownTimeout = 0 }},
+		{"listen.max_header_bytes", func(c *Config) { c.Listen.MaxHeaderBytes = 0 }},
+		{"repository.path", func(c *Config) { c.Repository.Path = "" }},
+		{"repository.targets_url", func(c *Config) { c.Repository.TargetsURL = "https://example.com/targets" }},
+		{"repository.metadata_url", func(c *Config) { c.Repository.MetadataURL = "ftp://example.com/metadata" }},
+		{"repository.metadata_url", func(c *Config) { c.Repository.MetadataURL = "https:///metadata" }},
+		{"repository.targets_url", func(c *Config) {
+			c.Repository.MetadataURL = "https://example.com/metadata"
+			c.Repository.TargetsURL = "example.com/targets"
+		}},
+		{"repository.expiry_policy", func(c *Config) { c.Repository.ExpiryPolicy = "sometimes" }},
+		{"repository.watch.mode", func(c *Config) { c.Repository.Watch.Mode = "sometimes" }},
+		{"repository.watch.debounce", func(c *Config) { c.Repository.Watch.Debounce = -1 }},
+		{"repository.watch.poll_interval", func(c *Config) { c.Repository.Watch.PollInterval = -1 }},
+		{"refresh.interval", func(c *Config) { c.Refresh.Interval = -1 }},
+		{"refresh.jitter", func(c *Config) { c.Refresh.Jitter = 1.5 }},
+		{"refresh.failure_policy", func(c *Config) { c.Refresh.FailurePolicy = "sometimes" }},
+		{"cache.size", func(c *Config) { c.Cache.Size = -1 }},
+		{"cache.ttl", func(c *Config) { c.Cache.TTL = -1 }},
+		{"headers.original_uri", func(c *Config) { c.Headers.OriginalURI = "" }},
+		{"headers.original_method", func(c *Config) { c.Headers.OriginalMethod = "X Method" }},
+		{"headers.original_host", func(c *Config) { c.Headers.OriginalHost = "X-Host:" }},
+		{"headers.request_id", func(c *Config) { c.Headers.RequestID = "X-Request-ID\n" }},
+		{"headers.client_ip", func(c *Config) { c.Headers.ClientIP = "X-Clïent" }},
+		{"logging.level", func(c *Config) { c.Logging.Level = "loud" }},
+		{"logging.format", func(c *Config) { c.Logging.Format = "xml" }},
+		{"logging.sample_allowed", func(c *Config) { c.Logging.SampleAllowed = -1 }},
+		{"tracing.exporter", func(c *Config) { c.Tracing.Exporter = "carrier-pigeon" }},
+		{"tracing.file", func(c *Config) { c.Tracing.Exporter = "file" }},
+		{"tracing.sample_ratio", func(c *Config) { c.Tracing.SampleRatio = -0.5 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if err == nil || !strings.HasPrefix(err.Error(), tt.key+": ") {
+				t.Errorf("Validate() error = %v, want an error for %s", err, tt.key)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryField(t *testing.T) {
+	cfg := Default()
+	cfg.Listen.MaxHeaderBytes = 0
+	cfg.Cache.Size = -1
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() succeeded with two invalid fields")
+	}
+	for _, key := range []string{"listen.max_header_bytes", "cache.size"} {
+		if !strings.Contains(err.Error(), key+": ") {
+			t.Errorf("Validate() error = %v, want an error for %s", err, key)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/matglas/tuf-client-verify/internal/tracing"
+	"github.com/matglas/tuf-client-verify/internal/tuf"
+)
+
+// Validate reports every invalid field of c, each prefixed with its config
+// file key
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(key string, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if _, port, err := net.SplitHostPort(c.Listen.Address); err != nil {
+		fail("listen.address", "%v", err)
+	} else if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		fail("listen.address", "invalid port %q", port)
+	}
+	for _, timeout := range []struct {
+		key   string
+		value Duration
+	}{
+		{"listen.read_header_timeout", c.Listen.ReadHeaderTimeout},
+		{"listen.read_timeout", c.Listen.ReadTimeout},
+		{"listen.write_timeout", c.Listen.WriteTimeout},
+		{"listen.idle_timeout", c.Listen.IdleTimeout},
+		{"listen.shutdown_delay", c.Listen.ShutdownDelay},
+	} {
+		if timeout.value < 0 {
+			fail(timeout.key, "must not be negative")
+		}
+	}
+	if c.Listen.ShutdownTimeout <= 0 {
+		fail("listen.shutdown_timeout", "must be positive")
+	}
+	if c.Listen.MaxHeaderBytes <= 0 {
+		fail("listen.max_header_bytes", "must be positive")
+	}
+
+	if c.Repository.MetadataURL == "" {
+		if c.Repository.Path == "" {
+			fail("repository.path", "required unless repository.metadata_url is set")
+		}
+		if c.Repository.TargetsURL != "" {
+			fail("repository.targets_url", "requires repository.metadata_url")
+		}
+	} else {
+		if err := validateURL(c.Repository.MetadataURL); err != nil {
+			fail("repository.metadata_url", "%v", err)
+		}
+		if c.Repository.TargetsURL != "" {
+			if err := validateURL(c.Repository.TargetsURL); err != nil {
+				fail("repository.targets_url", "%v", err)
+			}
+		}
+	}
+	if _, err := tuf.ParseExpiryPolicy(c.Repository.ExpiryPolicy); err != nil {
+		fail("repository.expiry_policy", "%v", err)
+	}
+	if _, err := tuf.ParseWatchMode(c.Repository.Watch.Mode); err != nil {
+		fail("repository.watch.mode", "%v", err)
+	}
+	if c.Repository.Watch.Debounce < 0 {
+		fail("repository.watch.debounce", "must not be negative")
+	}
+	if c.Repository.Watch.PollInterval < 0 {
+		fail("repository.watch.poll_interval", "must not be negative")
+	}
+
+	if c.Refresh.Interval < 0 {
+		fail("refresh.interval", "must not be negative")
+	}
+	if c.Refresh.Jitter < 0 || c.Refresh.Jitter > 1 {
+		fail("refresh.jitter", "must be between 0 and 1")
+	}
+	if _, err := tuf.ParseFailurePolicy(c.Refresh.FailurePolicy); err != nil {
+		fail("refresh.failure_policy", "%v", err)
+	}
+
+	if c.Cache.Size < 0 {
+		fail("cache.size", "must not be negative")
+	}
+	if c.Cache.TTL < 0 {
+		fail("cache.ttl", "must not be negative")
+	}
+
+	if !validHeaderName(c.Headers.OriginalURI) {
+		fail("headers.original_uri", "invalid header name %q", c.Headers.OriginalURI)
+	}
+	if !validHeaderName(c.Headers.OriginalMethod) {
+		fail("headers.original_method", "invalid header name %q", c.Headers.OriginalMethod)
+	}
+	if !validHeaderName(c.Headers.OriginalHost) {
+		fail("headers.original_host", "invalid header name %q", c.Headers.OriginalHost)
+	}
+	if !validHeaderName(c.Headers.RequestID) {
+		fail("headers.request_id", "invalid header name %q", c.Headers.RequestID)
+	}
+	if !validHeaderName(c.Headers.ClientIP) {
+		fail("headers.client_ip", "invalid header name %q", c.Headers.ClientIP)
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Logging.Level)); err != nil {
+		fail("logging.level", "unknown level %q", c.Logging.Level)
+	}
+	if c.Logging.Format != "text" && c.Logging.Format != "json" {
+		fail("logging.format", "must be text or json")
+	}
+	if c.Logging.SampleAllowed < 0 {
+		fail("logging.sample_allowed", "must not be negative")
+	}
+
+	if !tracing.HasExporter(c.Tracing.Exporter) {
+		fail("tracing.exporter", "unknown exporter %q", c.Tracing.Exporter)
+	}
+	if c.Tracing.Exporter == "file" && c.Tracing.File == "" {
+		fail("tracing.file", "required with the file exporter")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		fail("tracing.sample_ratio", "must be between 0 and 1")
+	}
+
+	return errors.Join(errs...)
+}
+
+// TUF returns the TUF client configuration for c, trusting rootBytes as the
+// initial root. c must be valid.
+func (c *Config) TUF(rootBytes []byte) (tuf.Config, error) {
+	expiryPolicy, err := tuf.ParseExpiryPolicy(c.Repository.ExpiryPolicy)
+	if err != nil {
+		return tuf.Config{}, err
+	}
+	failurePolicy, err := tuf.ParseFailurePolicy(c.Refresh.FailurePolicy)
+	if err != nil {
+		return tuf.Config{}, err
+	}
+	watchMode, err := tuf.ParseWatchMode(c.Repository.Watch.Mode)
+	if err != nil {
+		return tuf.Config{}, err
+	}
+
+	return tuf.Config{
+		RepoPath:               c.Repository.Path,
+		RootBytes:              rootBytes,
+		MetadataURL:            c.Repository.MetadataURL,
+		TargetsURL:             c.Repository.TargetsURL,
+		CacheDir:               c.Repository.CacheDir,
+		StateDir:               c.Repository.StateDir,
+		ExpiryPolicy:           expiryPolicy,
+		RefreshInterval:        time.Duration(c.Refresh.Interval),
+		RefreshJitter:          c.Refresh.Jitter,
+		FailurePolicy:          failurePolicy,
+		StrictDelegations:      c.Repository.StrictDelegations,
+		DecisionCacheSize:      c.Cache.Size,
+		DecisionCacheTTL:       time.Duration(c.Cache.TTL),
+		DecisionCacheKeyMethod: c.Cache.KeyMethod,
+		DecisionCacheKeyHost:   c.Cache.KeyHost,
+		WatchMode:              watchMode,
+		ReloadDebounce:         time.Duration(c.Repository.Watch.Debounce),
+		PollInterval:           time.Duration(c.Repository.Watch.PollInterval),
+	}, nil
+}
+
+// TraceConfig returns the tracing setup configuration for c
+func (c *Config) TraceConfig() tracing.Config {
+	return tracing.Config{
+		Exporter:    c.Tracing.Exporter,
+		File:        c.Tracing.File,
+		SampleRatio: c.Tracing.SampleRatio,
+	}
+}
+
+// validateURL checks that rawURL is an absolute http or https URL
+func validateURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("must be an http or https URL: %s", rawURL)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("missing host: %s", rawURL)
+	}
+
+	return nil
+}
+
+// validHeaderName reports whether name is a non-empty HTTP header field name
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	// Field names are RFC 7230 tokens
+	return !strings.ContainsFunc(name, func(r rune) bool {
+		return r <= ' ' || r >= 0x7f || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r)
+	})
+}
@@ -0,0 +1,59 @@
+// Package logging sets up structured logging with log/slog
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/matglas/tuf-client-verify/internal/config"
+)
+
+// New returns a logger writing to w in the configured format, discarding
+// records below the configured level
+func New(cfg config.LoggingConfig, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("invalid log level: %w", err)
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	switch cfg.Format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format: %s", cfg.Format)
+	}
+}
+
+// Sampler selects one in every n events, starting with the first. A Sampler
+// is safe for concurrent use.
+type Sampler struct {
+	every uint64
+	count atomic.Uint64
+}
+
+// NewSampler returns a Sampler selecting one in every n events. A Sampler
+// with n of 1 selects every event and one with n of 0 none.
+func NewSampler(n int) *Sampler {
+	return &Sampler{every: uint64(max(n, 0))}
+}
+
+// Sample reports whether the next event is selected
+func (s *Sampler) Sample() bool {
+	if s.every == 0 {
+		return false
+	}
+
+	return (s.count.Add(1)-1)%s.every == 0
+}
+
+// Milliseconds returns d in milliseconds, for latency fields that log
+// pipelines can aggregate
+func Milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
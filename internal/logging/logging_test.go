@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSamplerConcurrent(t *testing.T) {
+	const goroutines, eventsPerGoroutine = 8, 300
+	const events = goroutines * eventsPerGoroutine
+
+	tests := []struct {
+		n    int
+		want int64
+	}{
+		{n: 0, want: 0},
+		{n: 1, want: events},
+		{n: 3, want: (events + 2) / 3},
+	}
+
+	for _, tt := range tests {
+		sampler := NewSampler(tt.n)
+
+		var selected atomic.Int64
+		var wg sync.WaitGroup
+		for i := 0; i < goroutines; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < eventsPerGoroutine; j++ {
+					if sampler.Sample() {
+						selected.Add(1)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		if got := selected.Load(); got != tt.want {
+			t.Errorf("NewSampler(%d) selected %d of %d events, want %d", tt.n, got, events, tt.want)
+		}
+	}
+}
+
+func TestSamplerSelectsFirstEvent(t *testing.T) {
+	sampler := NewSampler(3)
+
+	var got []bool
+	for i := 0; i < 7; i++ {
+		got = append(got, sampler.Sample())
+	}
+
+	want := []bool{true, false, false, true, false, false, true}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Sample() sequence = %v, want %v", got, want)
+		}
+	}
+}
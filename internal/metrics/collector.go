@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/matglas/tuf-client-verify/internal/tuf"
+)
+
+var (
+	metadataVersionDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "metadata", "version"),
+		"Version of the verified metadata of each role. Hash bins are reported under the role delegating to them, with their highest version.",
+		[]string{"role"}, nil)
+	metadataExpiryDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "metadata", "expiry_seconds"),
+		"Seconds until the verified metadata of each role expires, negative once expired. Hash bins are reported under the role delegating to them, with their earliest expiry.",
+		[]string{"role"}, nil)
+	rolesUnavailableDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "metadata", "roles_unavailable"),
+		"Delegated roles whose metadata is missing or invalid.",
+		nil, nil)
+
+	refreshesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "metadata", "refreshes_total"),
+		"Metadata refreshes since start, by result.",
+		[]string{"result"}, nil)
+	degradedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "metadata", "degraded"),
+		"1 while the last metadata refresh has failed.",
+		nil, nil)
+	lastSuccessDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "metadata", "last_success_timestamp_seconds"),
+		"Unix time of the last successful metadata load.",
+		nil, nil)
+
+	cacheHitsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "decision_cache", "hits_total"),
+		"Decisions served from the decision cache.",
+		nil, nil)
+	cacheMissesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "decision_cache", "misses_total"),
+		"Decisions not found in the decision cache.",
+		nil, nil)
+	cacheEvictionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "decision_cache", "evictions_total"),
+		"Decisions evicted from the decision cache to make room.",
+		nil, nil)
+	cacheEntriesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "decision_cache", "entries"),
+		"Decisions currently in the decision cache.",
+		nil, nil)
+)
+
+// refresherCollector reads the metadata, refresh and cache state of a
+// Refresher at scrape time, so it always reflects the client in effect
+type refresherCollector struct {
+	refresher *tuf.Refresher
+}
+
+// newRefresherCollector returns a collector for refresher
+func newRefresherCollector(refresher *tuf.Refresher) *refresherCollector {
+	return &refresherCollector{refresher: refresher}
+}
+
+// Describe implements prometheus.Collector
+func (c *refresherCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		metadataVersionDesc, metadataExpiryDesc, rolesUnavailableDesc,
+		refreshesDesc, degradedDesc, lastSuccessDesc,
+		cacheHitsDesc, cacheMissesDesc, cacheEvictionsDesc, cacheEntriesDesc,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector
+func (c *refresherCollector) Collect(ch chan<- prometheus.Metric) {
+	client := c.refresher.Client()
+	now := time.Now()
+
+	for roleName, status := range roleSeries(client.GetTopLevelStatus(), client.GetRoleStatus()) {
+		ch <- prometheus.MustNewConstMetric(metadataVersionDesc, prometheus.GaugeValue, float64(status.Version), roleName)
+		ch <- prometheus.MustNewConstMetric(metadataExpiryDesc, prometheus.GaugeValue, status.Expires.Sub(now).Seconds(), roleName)
+	}
+	ch <- prometheus.MustNewConstMetric(rolesUnavailableDesc, prometheus.GaugeValue, float64(len(client.LoadErrors())))
+
+	status := c.refresher.Status()
+	ch <- prometheus.MustNewConstMetric(refreshesDesc, prometheus.CounterValue, float64(status.Successes), "success")
+	ch <- prometheus.MustNewConstMetric(refreshesDesc, prometheus.CounterValue, float64(status.Failures), "failure")
+	degraded := 0.0
+	if status.Degraded {
+		degraded = 1
+	}
+	ch <- prometheus.MustNewConstMetric(degradedDesc, prometheus.GaugeValue, degraded)
+	ch <- prometheus.MustNewConstMetric(lastSuccessDesc, prometheus.GaugeValue, float64(status.LastSuccess.UnixNano())/1e9)
+
+	stats := c.refresher.CacheStats()
+	ch <- prometheus.MustNewConstMetric(cacheHitsDesc, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(cacheMissesDesc, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(cacheEvictionsDesc, prometheus.CounterValue, float64(stats.Evictions))
+	ch <- prometheus.MustNewConstMetric(cacheEntriesDesc, prometheus.GaugeValue, float64(stats.Entries))
+}
+
+// roleSeries returns the version and expiry to report for each role label.
+// Hash bins are merged into the role delegating to them, keeping the highest
+// version and earliest expiry, so the number of series does not grow with
+// the number of bins.
+func roleSeries(topLevel, delegated map[string]tuf.RoleStatus) map[string]tuf.RoleStatus {
+	series := make(map[string]tuf.RoleStatus, len(topLevel))
+
+	add := func(roleName string, status tuf.RoleStatus) {
+		if status.State != tuf.RoleLoaded || status.Expires == nil {
+			return
+		}
+
+		merged, ok := series[roleName]
+		if !ok {
+			series[roleName] = status
+			return
+		}
+		merged.Version = max(merged.Version, status.Version)
+		if status.Expires.Before(*merged.Expires) {
+			merged.Expires = status.Expires
+		}
+		series[roleName] = merged
+	}
+
+	for roleName, status := range topLevel {
+		add(roleName, status)
+	}
+	for roleName, status := range delegated {
+		// Bins of bins are merged all the way up, and delegation cycles
+		// are cut at the first repeated role
+		label := roleName
+		seen := map[string]bool{label: true}
+		for delegated[label].HashBinOf != "" && !seen[delegated[label].HashBinOf] {
+			label = delegated[label].HashBinOf
+			seen[label] = true
+		}
+		add(label, status)
+	}
+
+	return series
+}
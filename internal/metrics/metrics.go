@@ -0,0 +1,108 @@
+// Package metrics exposes authorization decisions, metadata state, refreshes
+// and decision cache activity as Prometheus metrics
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/matglas/tuf-client-verify/internal/tuf"
+)
+
+const namespace = "tuf"
+
+// Results of an auth request, used as the result label
+const (
+	ResultAllowed = "allowed"
+	ResultDenied  = "denied"
+	ResultError   = "error"
+)
+
+// authBuckets are the /auth latency histogram buckets in seconds. Decisions
+// are usually served in microseconds, so the buckets start well below the
+// client library defaults.
+var authBuckets = []float64{
+	0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 1,
+}
+
+// Metrics records authorization metrics and serves them with the metadata,
+// refresh and cache state of a Refresher
+type Metrics struct {
+	registry     *prometheus.Registry
+	decisions    *prometheus.CounterVec
+	authDuration *prometheus.HistogramVec
+}
+
+// New returns Metrics reporting on refresher
+func New(refresher *tuf.Refresher) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		decisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "decisions_total",
+			Help:      "Authorization decisions by result, deny reason and the role that signed the allowed target, with hash bins counted under the role delegating to them.",
+		}, []string{"result", "reason", "role"}),
+		authDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "auth_request_duration_seconds",
+			Help:      "Time taken to answer /auth requests, by result.",
+			Buckets:   authBuckets,
+		}, []string{"result"}),
+	}
+
+	m.registry.MustRegister(
+		m.decisions,
+		m.authDuration,
+		newRefresherCollector(refresher),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveDecision counts decision. Allowed decisions are labelled with the
+// role that signed the target, denied ones with the deny reason.
+func (m *Metrics) ObserveDecision(decision tuf.Decision) {
+	if decision.Allowed {
+		m.decisions.WithLabelValues(ResultAllowed, "", decisionRole(decision)).Inc()
+		return
+	}
+
+	m.decisions.WithLabelValues(ResultDenied, string(decision.Reason), "").Inc()
+}
+
+// decisionRole returns the role label of an allowed decision: the role that
+// signed the target, or if that is a hash bin, the role delegating to the
+// bins. A repository may have thousands of bins, which would each become a
+// separate series.
+func decisionRole(decision tuf.Decision) string {
+	role := decision.RoleChain[len(decision.RoleChain)-1]
+
+	for i := len(decision.Consulted) - 1; i >= 0; i-- {
+		consulted := decision.Consulted[i]
+		if consulted.Role != role {
+			continue
+		}
+		if consulted.SuccinctBitLength == 0 && len(consulted.PathHashPrefixes) == 0 {
+			break
+		}
+		role = consulted.Delegator
+	}
+
+	return role
+}
+
+// ObserveAuth records the duration of an /auth request with the given result
+func (m *Metrics) ObserveAuth(result string, duration time.Duration) {
+	m.authDuration.WithLabelValues(result).Observe(duration.Seconds())
+}
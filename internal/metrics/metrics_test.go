@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matglas/tuf-client-verify/internal/tuf"
+)
+
+func TestDecisionRoleCollapsesHashBins(t *testing.T) {
+	tests := []struct {
+		name     string
+		decision tuf.Decision
+		want     string
+	}{
+		{
+			name: "path delegation",
+			decision: tuf.Decision{
+				RoleChain: []string{"targets", "library"},
+				Consulted: []tuf.ConsultedRole{
+					{Role: "targets"},
+					{Role: "library", Delegator: "targets", Paths: []string{"/v2/library/*"}},
+				},
+			},
+			want: "library",
+		},
+		{
+			name: "succinct bin",
+			decision: tuf.Decision{
+				RoleChain: []string{"targets", "library", "library-hb-3"},
+				Consulted: []tuf.ConsultedRole{
+					{Role: "targets"},
+					{Role: "library", Delegator: "targets", Paths: []string{"/v2/library/*"}},
+					{Role: "library-hb-3", Delegator: "library", SuccinctBitLength: 2},
+				},
+			},
+			want: "library",
+		},
+		{
+			name: "hash prefix bin",
+			decision: tuf.Decision{
+				RoleChain: []string{"targets", "bin-0"},
+				Consulted: []tuf.ConsultedRole{
+					{Role: "targets"},
+					{Role: "bin-0", Delegator: "targets", PathHashPrefixes: []string{"0"}},
+				},
+			},
+			want: "targets",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decisionRole(tt.decision); got != tt.want {
+				t.Errorf("decisionRole() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoleSeriesMergesHashBins(t *testing.T) {
+	now := time.Now()
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	topLevel := map[string]tuf.RoleStatus{
+		"targets": {State: tuf.RoleLoaded, Version: 2, Expires: at(48 * time.Hour)},
+	}
+	delegated := map[string]tuf.RoleStatus{
+		"library":      {State: tuf.RoleLoaded, Version: 3, Expires: at(24 * time.Hour)},
+		"library-hb-0": {State: tuf.RoleLoaded, Version: 5, Expires: at(36 * time.Hour), HashBinOf: "library"},
+		"library-hb-1": {State: tuf.RoleLoaded, Version: 4, Expires: at(time.Hour), HashBinOf: "library"},
+		"library-hb-2": {State: tuf.RoleMissing, HashBinOf: "library"},
+	}
+
+	series := roleSeries(topLevel, delegated)
+	if len(series) != 2 {
+		t.Fatalf("roleSeries() = %v, want targets and library only", series)
+	}
+	if got := series["targets"]; got.Version != 2 || !got.Expires.Equal(*at(48 * time.Hour)) {
+		t.Errorf("targets series = version %d, expires %v", got.Version, got.Expires)
+	}
+	if got := series["library"]; got.Version != 5 || !got.Expires.Equal(*at(time.Hour)) {
+		t.Errorf("library series = version %d, expires %v, want version 5 expiring with library-hb-1", got.Version, got.Expires)
+	}
+}
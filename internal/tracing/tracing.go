@@ -0,0 +1,162 @@
+// Package tracing installs the OpenTelemetry tracer provider and W3C trace
+// context propagation, exporting spans through a pluggable exporter
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// ServiceName identifies this service in exported spans
+const ServiceName = "tuf-client-verify"
+
+// ExporterNone disables span export
+const ExporterNone = "none"
+
+// Config selects and configures the span exporter
+type Config struct {
+	// Exporter is the name of a registered exporter, or ExporterNone
+	Exporter string
+
+	// File is the path spans are written to by the file exporter
+	File string
+
+	// SampleRatio is the fraction of new traces that are sampled. Traces
+	// started by the proxy keep the proxy's sampling decision.
+	SampleRatio float64
+}
+
+// ExporterFactory creates a span exporter for cfg
+type ExporterFactory func(cfg Config) (sdktrace.SpanExporter, error)
+
+var (
+	exportersMu sync.RWMutex
+	exporters   = map[string]ExporterFactory{
+		"stdout": newStdoutExporter,
+		"file":   newFileExporter,
+	}
+)
+
+// RegisterExporter makes an exporter available under name, replacing any
+// exporter registered under that name before
+func RegisterExporter(name string, factory ExporterFactory) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+
+	exporters[name] = factory
+}
+
+// Exporters returns the names of the registered exporters, sorted
+func Exporters() []string {
+	exportersMu.RLock()
+	defer exportersMu.RUnlock()
+
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// HasExporter reports whether name is ExporterNone or a registered exporter
+func HasExporter(name string) bool {
+	if name == ExporterNone {
+		return true
+	}
+
+	exportersMu.RLock()
+	defer exportersMu.RUnlock()
+
+	_, ok := exporters[name]
+	return ok
+}
+
+// Setup installs W3C trace context propagation and, unless the exporter is
+// ExporterNone, a global tracer provider exporting through it. The returned
+// function flushes pending spans and shuts the provider down.
+func Setup(cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if cfg.Exporter == ExporterNone {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exportersMu.RLock()
+	factory, ok := exporters[cfg.Exporter]
+	exportersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown trace exporter: %s", cfg.Exporter)
+	}
+
+	exporter, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", cfg.Exporter, err)
+	}
+
+	provider := NewProvider(exporter, cfg.SampleRatio)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// NewProvider returns a tracer provider batching spans to exporter and
+// sampling sampleRatio of the traces it starts itself
+func NewProvider(exporter sdktrace.SpanExporter, sampleRatio float64) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", ServiceName))),
+	)
+}
+
+// newStdoutExporter writes spans to standard output as JSON, one per line
+func newStdoutExporter(Config) (sdktrace.SpanExporter, error) {
+	return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+}
+
+// fileExporter writes spans to a file and closes it on shutdown
+type fileExporter struct {
+	sdktrace.SpanExporter
+	file *os.File
+}
+
+// newFileExporter appends spans to cfg.File as JSON, one per line
+func newFileExporter(cfg Config) (sdktrace.SpanExporter, error) {
+	if cfg.File == "" {
+		return nil, errors.New("no trace file configured")
+	}
+
+	file, err := os.OpenFile(cfg.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace file: %w", err)
+	}
+
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &fileExporter{SpanExporter: exporter, file: file}, nil
+}
+
+// Shutdown shuts the exporter down and closes the file
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.SpanExporter.Shutdown(ctx), e.file.Close())
+}
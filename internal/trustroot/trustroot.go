@@ -0,0 +1,23 @@
+// Package trustroot provides the TUF root of trust compiled into the binary.
+package trustroot
+
+import "embed"
+
+// rootFile is the embedded initial trusted root metadata. It is populated at
+// build time by placing a root.json in the embedded directory, which
+// scripts/generate-tuf-repo.go does for development repositories.
+const rootFile = "embedded/root.json"
+
+//go:embed embedded
+var embedded embed.FS
+
+// Root returns the embedded initial trusted root metadata, or nil when the
+// binary was built without one
+func Root() []byte {
+	rootBytes, err := embedded.ReadFile(rootFile)
+	if err != nil || len(rootBytes) == 0 {
+		return nil
+	}
+
+	return rootBytes
+}
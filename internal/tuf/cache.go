@@ -0,0 +1,252 @@
+package tuf
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheStats reports decision cache activity
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+}
+
+// decisionCache is an LRU cache of decisions keyed by normalized path, and
+// by request method and host when configured. Decisions depend only on the
+// request and the verified metadata, so the cache belongs to one version of
+// the metadata at a time and is emptied whenever a client with different
+// metadata is swapped in. No entry outlives the earliest expiry of that
+// metadata.
+type decisionCache struct {
+	capacity  int
+	ttl       time.Duration
+	keyMethod bool
+	keyHost   bool
+
+	mu      sync.Mutex
+	client  *Client
+	version metadataVersion
+	expires time.Time
+	entries map[cacheKey]*list.Element
+	// order holds the entries from most to least recently used
+	order *list.List
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+// cacheKey identifies a cached decision. method and host are empty unless
+// the cache is configured to key by them.
+type cacheKey struct {
+	path   string
+	method string
+	host   string
+}
+
+// cacheEntry is a cached decision and the time it stops being valid, which
+// is zero if it does not expire
+type cacheEntry struct {
+	key      cacheKey
+	decision Decision
+	expires  time.Time
+}
+
+// metadataVersion identifies the verified metadata a client decides from.
+// Clients with equal versions make the same decisions.
+type metadataVersion struct {
+	root, timestamp, snapshot, targets int64
+	// unavailable lists the roles and delegations that could not be
+	// loaded, as the same metadata versions may load differently when a
+	// role's metadata file appears or is fixed
+	unavailable string
+}
+
+// metadataVersion returns the version of the metadata c decides from. The
+// snapshot pins the version of every delegated role.
+func (c *Client) metadataVersion() metadataVersion {
+	var unavailable []string
+	for _, roleErr := range c.LoadErrors() {
+		unavailable = append(unavailable, roleErr.Delegator+"/"+roleErr.Role)
+	}
+
+	return metadataVersion{
+		root:        c.rootMeta.Signed.Version,
+		timestamp:   c.timestampMeta.Signed.Version,
+		snapshot:    c.snapshotMeta.Signed.Version,
+		targets:     c.targetsMeta.Signed.Version,
+		unavailable: strings.Join(unavailable, ","),
+	}
+}
+
+// newDecisionCache returns a cache holding up to capacity decisions, each for
+// at most ttl if ttl is positive, keyed by request method and host if
+// keyMethod and keyHost are set
+func newDecisionCache(capacity int, ttl time.Duration, keyMethod, keyHost bool) *decisionCache {
+	return &decisionCache{
+		capacity:  capacity,
+		ttl:       ttl,
+		keyMethod: keyMethod,
+		keyHost:   keyHost,
+		entries:   make(map[cacheKey]*list.Element),
+		order:     list.New(),
+	}
+}
+
+// key returns the cache key of req, whose path must be normalized
+func (dc *decisionCache) key(req DecisionRequest) cacheKey {
+	key := cacheKey{path: req.Path}
+	if dc.keyMethod {
+		key.method = strings.ToUpper(req.Method)
+	}
+	if dc.keyHost {
+		key.host = strings.ToLower(req.Host)
+	}
+
+	return key
+}
+
+// get returns a copy of the decision client made for req, if cached and
+// still valid at now
+func (dc *decisionCache) get(client *Client, req DecisionRequest, now time.Time) (Decision, bool) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	dc.bind(client)
+
+	element, ok := dc.entries[dc.key(req)]
+	if !ok {
+		dc.misses.Add(1)
+		return Decision{}, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if !entry.expires.IsZero() && now.After(entry.expires) {
+		dc.remove(element)
+		dc.misses.Add(1)
+		return Decision{}, false
+	}
+
+	dc.order.MoveToFront(element)
+	dc.hits.Add(1)
+
+	return entry.decision.clone(), true
+}
+
+// put caches a copy of the decision client made for req at now, evicting the
+// least recently used entry when full
+func (dc *decisionCache) put(client *Client, req DecisionRequest, decision Decision, now time.Time) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	dc.bind(client)
+
+	expires := dc.expires
+	if dc.ttl > 0 && (expires.IsZero() || now.Add(dc.ttl).Before(expires)) {
+		expires = now.Add(dc.ttl)
+	}
+	if !expires.IsZero() && !now.Before(expires) {
+		return
+	}
+
+	key := dc.key(req)
+	entry := &cacheEntry{key: key, decision: decision.clone(), expires: expires}
+	if element, ok := dc.entries[key]; ok {
+		element.Value = entry
+		dc.order.MoveToFront(element)
+		return
+	}
+
+	for dc.order.Len() >= dc.capacity {
+		dc.remove(dc.order.Back())
+		dc.evictions.Add(1)
+	}
+
+	dc.entries[key] = dc.order.PushFront(entry)
+}
+
+// bind empties the cache if its decisions were made from metadata other than
+// client's, and bounds new entries by client's metadata expiry. A reload of
+// unchanged metadata keeps the cached decisions.
+func (dc *decisionCache) bind(client *Client) {
+	if dc.client == client {
+		return
+	}
+
+	version := client.metadataVersion()
+	if dc.client != nil && dc.version == version {
+		dc.client = client
+		return
+	}
+
+	dc.client = client
+	dc.version = version
+	dc.entries = make(map[cacheKey]*list.Element)
+	dc.order.Init()
+
+	// Expired metadata is only served under ExpiryPolicyAllowExpired, where
+	// expiry no longer changes decisions
+	dc.expires = time.Time{}
+	if client.expiryPolicy != ExpiryPolicyAllowExpired {
+		dc.expires = client.earliestExpiry()
+	}
+}
+
+// remove deletes element from the cache
+func (dc *decisionCache) remove(element *list.Element) {
+	dc.order.Remove(element)
+	delete(dc.entries, element.Value.(*cacheEntry).key)
+}
+
+// stats returns the cache counters and current size
+func (dc *decisionCache) stats() CacheStats {
+	dc.mu.Lock()
+	entries := dc.order.Len()
+	dc.mu.Unlock()
+
+	return CacheStats{
+		Hits:      dc.hits.Load(),
+		Misses:    dc.misses.Load(),
+		Evictions: dc.evictions.Load(),
+		Entries:   entries,
+	}
+}
+
+// decide returns client's decision for req, from the cache when enabled,
+// and whether it was cached
+func (r *Refresher) decide(ctx context.Context, client *Client, req DecisionRequest) (Decision, bool, error) {
+	if r.cache == nil {
+		decision, err := client.DecideContext(ctx, req.Path)
+		return decision, false, err
+	}
+
+	req.Path = normalizePath(req.Path)
+	now := client.now()
+	if decision, ok := r.cache.get(client, req, now); ok {
+		return decision, true, nil
+	}
+
+	decision, err := client.DecideContext(ctx, req.Path)
+	if err != nil {
+		return decision, false, err
+	}
+	r.cache.put(client, req, decision, now)
+
+	return decision, false, nil
+}
+
+// CacheStats returns the decision cache counters. They are all zero when the
+// cache is disabled.
+func (r *Refresher) CacheStats() CacheStats {
+	if r.cache == nil {
+		return CacheStats{}
+	}
+
+	return r.cache.stats()
+}
@@ -0,0 +1,150 @@
+package tuf
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+// writeCacheTestRepo writes a repository that allows /v2/library/alpine
+// through the library role to dir
+func writeCacheTestRepo(t *testing.T, dir string) *testRepo {
+	t.Helper()
+
+	repo := newTestRepo(t, time.Now().Add(24*time.Hour))
+	repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "library", Paths: []string{"/v2/library/*"}})
+	repo.addTarget("library", "/v2/library/alpine")
+	repo.write(dir)
+
+	return repo
+}
+
+func TestDecisionCacheKey(t *testing.T) {
+	dir := t.TempDir()
+	writeCacheTestRepo(t, dir)
+	client, err := NewClient(Config{RepoPath: dir})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	cached := DecisionRequest{Path: "/v2/library/alpine", Method: "GET", Host: "registry-a.example"}
+	tests := []struct {
+		name               string
+		keyMethod, keyHost bool
+		req                DecisionRequest
+		hit                bool
+	}{
+		{"path only", false, false, DecisionRequest{Path: cached.Path, Method: "HEAD", Host: "registry-b.example"}, true},
+		{"method differs", true, false, DecisionRequest{Path: cached.Path, Method: "HEAD", Host: cached.Host}, false},
+		{"method case", true, false, DecisionRequest{Path: cached.Path, Method: "get", Host: "registry-b.example"}, true},
+		{"host differs", false, true, DecisionRequest{Path: cached.Path, Method: "HEAD", Host: "registry-b.example"}, false},
+		{"host case", false, true, DecisionRequest{Path: cached.Path, Method: "HEAD", Host: "Registry-A.example"}, true},
+		{"method and host", true, true, cached, true},
+		{"path differs", true, true, DecisionRequest{Path: "/v2/library/busybox", Method: cached.Method, Host: cached.Host}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newDecisionCache(10, 0, tt.keyMethod, tt.keyHost)
+			now := time.Now()
+
+			decision, err := client.Decide(cached.Path)
+			if err != nil {
+				t.Fatalf("Decide() error = %v", err)
+			}
+			cache.put(client, cached, decision, now)
+
+			if _, hit := cache.get(client, tt.req, now); hit != tt.hit {
+				t.Errorf("get(%+v) hit = %v, want %v", tt.req, hit, tt.hit)
+			}
+		})
+	}
+}
+
+func TestDecisionCacheKeptForUnchangedMetadata(t *testing.T) {
+	dir := t.TempDir()
+	repo := writeCacheTestRepo(t, dir)
+
+	loadClient := func() *Client {
+		client, err := NewClient(Config{RepoPath: dir})
+		if err != nil {
+			t.Fatalf("NewClient() error = %v", err)
+		}
+		return client
+	}
+
+	cache := newDecisionCache(10, 0, false, false)
+	req := DecisionRequest{Path: "/v2/library/alpine"}
+	now := time.Now()
+
+	client := loadClient()
+	decision, err := client.Decide(req.Path)
+	if err != nil {
+		t.Fatalf("Decide() error = %v", err)
+	}
+	cache.put(client, req, decision, now)
+
+	// A reload of the same metadata keeps the cached decision
+	if _, hit := cache.get(loadClient(), req, now); !hit {
+		t.Error("get() missed after reloading unchanged metadata")
+	}
+
+	// New metadata empties the cache
+	repo.targets[metadata.TARGETS].Signed.Version++
+	repo.snapshot.Signed.Version++
+	repo.write(dir)
+	if _, hit := cache.get(loadClient(), req, now); hit {
+		t.Error("get() hit after reloading changed metadata")
+	}
+}
+
+func TestDecisionCacheReturnsCopies(t *testing.T) {
+	dir := t.TempDir()
+	writeCacheTestRepo(t, dir)
+	client, err := NewClient(Config{RepoPath: dir})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	cache := newDecisionCache(10, 0, false, false)
+	req := DecisionRequest{Path: "/v2/library/alpine"}
+	now := time.Now()
+
+	want, err := client.Decide(req.Path)
+	if err != nil {
+		t.Fatalf("Decide() error = %v", err)
+	}
+
+	// Neither the decision put nor the decisions returned share state with
+	// the cached entry
+	put, err := client.Decide(req.Path)
+	if err != nil {
+		t.Fatalf("Decide() error = %v", err)
+	}
+	cache.put(client, req, put, now)
+	mutateDecision(put)
+
+	got, _ := cache.get(client, req, now)
+	mutateDecision(got)
+
+	got, hit := cache.get(client, req, now)
+	if !hit {
+		t.Fatal("get() missed")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("get() = %+v, want %+v", got, want)
+	}
+}
+
+// mutateDecision changes every slice and map a decision holds
+func mutateDecision(decision Decision) {
+	decision.RoleChain[0] = "changed"
+	decision.ConsultedRoles[0] = "changed"
+	decision.Consulted[1].Paths[0] = "changed"
+	decision.Versions[metadata.TARGETS] = -1
+	for algorithm := range decision.Target.Hashes {
+		decision.Target.Hashes[algorithm] = "changed"
+	}
+}
@@ -3,26 +3,213 @@
 package tuf
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	gopath "path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/theupdateframework/go-tuf/v2/metadata"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultMaxDelegations is the default bound on roles visited per lookup
+	DefaultMaxDelegations = 32
+	// DefaultMaxDelegatedRoles is the default bound on delegated roles
+	// loaded, which leaves room for hash bin delegations with 2^16 bins
+	DefaultMaxDelegatedRoles = 1 << 17
+	// DefaultMaxDelegationDepth is the default bound on delegation nesting
+	DefaultMaxDelegationDepth = 8
 )
 
 // Client wraps TUF metadata for path verification
 type Client struct {
-	rootMeta      *metadata.Metadata[metadata.RootType]
+	rootMeta *metadata.Metadata[metadata.RootType]
+	// rootBytes is the raw trusted root in local mode, persisted to the
+	// state directory once loading succeeds
+	rootBytes     []byte
+	timestampMeta *metadata.Metadata[metadata.TimestampType]
+	snapshotMeta  *metadata.Metadata[metadata.SnapshotType]
 	targetsMeta   *metadata.Metadata[metadata.TargetsType]
 	delegatedMeta map[string]*metadata.Metadata[metadata.TargetsType]
+
+	// hashBinDelegators maps each delegated role loaded as a hash bin, by
+	// path hash prefixes or succinctly, to the role delegating to it
+	hashBinDelegators map[string]string
+
+	// delegationDepths holds the smallest delegation depth each loaded
+	// delegated role was reached at, which its own delegations were loaded
+	// to
+	delegationDepths map[string]int
+
+	// roleErrors holds the delegated roles whose metadata was unavailable,
+	// and delegationErrors the delegations to roles whose metadata is not
+	// signed by the keys the delegator assigns to them
+	roleErrors       map[string]*RoleLoadError
+	delegationErrors map[delegation]*RoleLoadError
+
+	// index and patterns are built once loading completes. index holds
+	// the lookup result of every allowed target path, and patterns the
+	// delegation patterns of each delegating role.
+	index    *targetIndex
+	patterns map[string]*patternIndex
+
+	// trustedVersions holds the last verified version of each role, which
+	// loaded metadata must not be older than
+	trustedVersions map[string]trustedVersion
+
+	now                func() time.Time
+	expiryPolicy       ExpiryPolicy
+	maxDelegations     int
+	maxDelegatedRoles  int
+	maxDelegationDepth int
 }
 
 // Config holds configuration for TUF client initialization
 type Config struct {
 	// RepoPath is the local path to the TUF repository
 	RepoPath string
+
+	// RootBytes is the initial trusted root metadata. When set, the
+	// repository's root.json is not used as the trust anchor and only root
+	// versions that chain back to RootBytes are accepted.
+	RootBytes []byte
+
+	// MetadataURL enables remote mode: metadata is fetched from this HTTP(S)
+	// TUF repository through the go-tuf updater instead of read from
+	// RepoPath. Remote mode requires RootBytes.
+	MetadataURL string
+
+	// TargetsURL is the base URL of target files in remote mode.
+	// Defaults to MetadataURL + "/targets".
+	TargetsURL string
+
+	// CacheDir is the local directory where remote mode caches verified
+	// metadata. Caching is disabled when empty.
+	CacheDir string
+
+	// StateDir is an optional local directory where the client persists
+	// trusted state across restarts: the newest verified root and the last
+	// verified version of every other role, which protects against rollback
+	// to older metadata. Without it, rollback is only detected against the
+	// versions trusted since the process started.
+	StateDir string
+
+	// Clock returns the reference time for metadata expiry checks.
+	// Defaults to time.Now.
+	Clock func() time.Time
+
+	// ExpiryPolicy controls how expired metadata is handled.
+	// Defaults to ExpiryPolicyDeny.
+	ExpiryPolicy ExpiryPolicy
+
+	// MaxDelegations bounds the number of roles visited per path lookup.
+	// Defaults to DefaultMaxDelegations.
+	MaxDelegations int
+
+	// MaxDelegatedRoles bounds the number of delegated roles loaded, and
+	// loading fails when the metadata delegates to more. Hash bin
+	// delegations load many roles but visit few per lookup, so this bound
+	// is separate from MaxDelegations.
+	// Defaults to DefaultMaxDelegatedRoles.
+	MaxDelegatedRoles int
+
+	// MaxDelegationDepth bounds how many levels of nested delegations are
+	// followed below the top-level targets role.
+	// Defaults to DefaultMaxDelegationDepth.
+	MaxDelegationDepth int
+
+	// RefreshInterval is how often a Refresher reloads metadata.
+	// Zero disables periodic refresh.
+	RefreshInterval time.Duration
+
+	// RefreshJitter randomizes each refresh interval by up to this fraction
+	// of RefreshInterval in either direction, so that replicas do not all
+	// hit the repository at once. Must be between 0 and 1.
+	RefreshJitter float64
+
+	// DecisionCacheSize is the number of decisions a Refresher caches.
+	// Zero disables the cache.
+	DecisionCacheSize int
+
+	// DecisionCacheTTL bounds how long a cached decision is used. Cached
+	// decisions never outlive the metadata they were made from, which also
+	// applies when DecisionCacheTTL is zero.
+	DecisionCacheTTL time.Duration
+
+	// DecisionCacheKeyMethod and DecisionCacheKeyHost add the request
+	// method and host to the decision cache key, so that a service shared
+	// by several registries or methods never answers one request from a
+	// decision cached for another
+	DecisionCacheKeyMethod bool
+	DecisionCacheKeyHost   bool
+
+	// StrictDelegations makes loading fail when any delegated role's
+	// metadata is missing, cannot be parsed or is not signed by the keys
+	// its delegator assigns to it. Otherwise such roles are reported by
+	// Client.LoadErrors and lookups reaching them are denied.
+	StrictDelegations bool
+
+	// FailurePolicy controls how a Refresher authorizes paths after a
+	// refresh fails. Defaults to FailOpenUntilExpiry.
+	FailurePolicy FailurePolicy
+
+	// WatchMode selects how a Refresher watches a local repository for
+	// changes. Defaults to WatchAuto.
+	WatchMode WatchMode
+
+	// ReloadDebounce is how long repository changes must be quiet before a
+	// watching Refresher reloads. Defaults to DefaultReloadDebounce.
+	ReloadDebounce time.Duration
+
+	// PollInterval is how often WatchPoll scans the repository.
+	// Defaults to DefaultPollInterval.
+	PollInterval time.Duration
+}
+
+// metadataReader returns the raw bytes of a role's metadata file. version is
+// the version pinned by the trusted timestamp or snapshot, or 0 if the role
+// is not pinned, as for the timestamp itself.
+type metadataReader func(roleName string, version int64) ([]byte, error)
+
+// localReader reads metadata files from a local repository directory
+func (c *Client) localReader(repoPath string) metadataReader {
+	return func(roleName string, version int64) ([]byte, error) {
+		return os.ReadFile(filepath.Join(repoPath, c.metadataFileName(roleName, version)))
+	}
+}
+
+// metadataFileName returns the repository file name of a role's metadata.
+// When root enables consistent snapshots, pinned versions are read from
+// "<version>.<role>.json" so that a repository publishing a new version never
+// serves the client a mix of old and new files.
+func (c *Client) metadataFileName(roleName string, version int64) string {
+	if c.rootMeta.Signed.ConsistentSnapshot && version > 0 {
+		return fmt.Sprintf("%d.%s.json", version, roleName)
+	}
+
+	return roleName + ".json"
+}
+
+// metaVersion returns the version that meta pins for fileName, or 0 if it is
+// not listed
+func metaVersion(meta map[string]*metadata.MetaFiles, fileName string) int64 {
+	if info, ok := meta[fileName]; ok && info != nil {
+		return info.Version
+	}
+
+	return 0
 }
 
 // NewLocalFileClient creates a TUF client that reads from local files
@@ -36,153 +223,661 @@ func NewLocalFileClient(repoPath string) (*Client, error) {
 
 // NewClient creates a new TUF client with the given configuration
 func NewClient(cfg Config) (*Client, error) {
-	// Load root metadata
-	rootBytes, err := os.ReadFile(filepath.Join(cfg.RepoPath, "root.json"))
+	return newClient(cfg, nil)
+}
+
+// newClient creates a new TUF client. A non-nil previous client is the state
+// being refreshed: without a state directory its trusted versions carry over,
+// so a refresh never accepts metadata older than what previous trusted.
+func newClient(cfg Config, previous *Client) (*Client, error) {
+	now := cfg.Clock
+	if now == nil {
+		now = time.Now
+	}
+
+	maxDelegations := cfg.MaxDelegations
+	if maxDelegations <= 0 {
+		maxDelegations = DefaultMaxDelegations
+	}
+
+	maxDelegatedRoles := cfg.MaxDelegatedRoles
+	if maxDelegatedRoles <= 0 {
+		maxDelegatedRoles = DefaultMaxDelegatedRoles
+	}
+
+	maxDelegationDepth := cfg.MaxDelegationDepth
+	if maxDelegationDepth <= 0 {
+		maxDelegationDepth = DefaultMaxDelegationDepth
+	}
+
+	trustedVersions := make(map[string]trustedVersion)
+	if previous != nil {
+		for roleName, trusted := range previous.trustedVersions {
+			trustedVersions[roleName] = trusted
+		}
+	}
+	if cfg.StateDir != "" {
+		var err error
+		trustedVersions, err = loadTrustedVersions(cfg.StateDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load trusted metadata versions: %w", err)
+		}
+	}
+
+	client := &Client{
+		delegatedMeta:      make(map[string]*metadata.Metadata[metadata.TargetsType]),
+		hashBinDelegators:  make(map[string]string),
+		delegationDepths:   make(map[string]int),
+		roleErrors:         make(map[string]*RoleLoadError),
+		delegationErrors:   make(map[delegation]*RoleLoadError),
+		trustedVersions:    trustedVersions,
+		now:                now,
+		expiryPolicy:       cfg.ExpiryPolicy,
+		maxDelegations:     maxDelegations,
+		maxDelegatedRoles:  maxDelegatedRoles,
+		maxDelegationDepth: maxDelegationDepth,
+	}
+
+	// Load top-level metadata from a remote repository or local files
+	var readMetadata metadataReader
+	var err error
+	if cfg.MetadataURL != "" {
+		readMetadata, err = client.loadRemoteTopLevel(cfg)
+	} else {
+		readMetadata = client.localReader(cfg.RepoPath)
+		err = client.loadTopLevel(cfg, readMetadata)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.checkTopLevelRollback(); err != nil {
+		return nil, fmt.Errorf("failed to load top-level metadata: %w", err)
+	}
+
+	// Load delegated targets metadata
+	if err := client.loadDelegatedTargets(readMetadata); err != nil {
+		return nil, fmt.Errorf("failed to load delegated targets: %w", err)
+	}
+
+	if loadErrors := client.LoadErrors(); cfg.StrictDelegations && len(loadErrors) > 0 {
+		var errs []error
+		for _, roleErr := range loadErrors {
+			errs = append(errs, roleErr)
+		}
+		return nil, fmt.Errorf("failed to load delegated targets: %w", errors.Join(errs...))
+	}
+
+	client.buildIndex()
+
+	// Trusted state is only persisted once all metadata has been verified,
+	// so a root that fails to vouch for the rest is never trusted on restart
+	if cfg.StateDir != "" {
+		if client.rootBytes != nil {
+			if err := persistRoot(cfg.StateDir, client.rootBytes); err != nil {
+				return nil, fmt.Errorf("failed to persist trusted root metadata: %w", err)
+			}
+		}
+		if err := persistTrustedVersions(cfg.StateDir, client.trustedVersions); err != nil {
+			return nil, fmt.Errorf("failed to persist trusted metadata versions: %w", err)
+		}
+	}
+
+	return client, nil
+}
+
+// loadTopLevel loads and verifies root, timestamp, snapshot and targets
+// metadata, enforcing the timestamp -> snapshot -> targets version and hash
+// chain
+func (c *Client) loadTopLevel(cfg Config, readMetadata metadataReader) error {
+	// Load the trusted root, following any root rotations in the repository
+	rootMeta, rootBytes, err := loadTrustedRoot(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read root metadata: %w", err)
+		return err
 	}
 
-	rootMeta := &metadata.Metadata[metadata.RootType]{}
-	if err := json.Unmarshal(rootBytes, rootMeta); err != nil {
-		return nil, fmt.Errorf("failed to parse root metadata: %w", err)
+	if err := checkExpiry(c.expiryPolicy, metadata.ROOT, rootMeta.Signed.Expires, c.now()); err != nil {
+		return fmt.Errorf("failed to load root metadata: %w", err)
+	}
+	c.rootMeta = rootMeta
+	c.rootBytes = rootBytes
+
+	// Load timestamp metadata
+	timestampBytes, err := readMetadata(metadata.TIMESTAMP, 0)
+	if err != nil {
+		return fmt.Errorf("failed to read timestamp metadata: %w", err)
+	}
+
+	timestampMeta := &metadata.Metadata[metadata.TimestampType]{}
+	if err := json.Unmarshal(timestampBytes, timestampMeta); err != nil {
+		return fmt.Errorf("failed to parse timestamp metadata: %w", err)
+	}
+
+	if err := verifyTopLevel(c.rootMeta, metadata.TIMESTAMP, timestampMeta.Signed.Type, timestampMeta); err != nil {
+		return fmt.Errorf("failed to verify timestamp metadata: %w", err)
+	}
+
+	if err := checkExpiry(c.expiryPolicy, metadata.TIMESTAMP, timestampMeta.Signed.Expires, c.now()); err != nil {
+		return fmt.Errorf("failed to load timestamp metadata: %w", err)
+	}
+
+	// Load snapshot metadata, which must be the version timestamp signed for
+	snapshotBytes, err := readMetadata(metadata.SNAPSHOT, metaVersion(timestampMeta.Signed.Meta, "snapshot.json"))
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot metadata: %w", err)
+	}
+
+	snapshotMeta := &metadata.Metadata[metadata.SnapshotType]{}
+	if err := json.Unmarshal(snapshotBytes, snapshotMeta); err != nil {
+		return fmt.Errorf("failed to parse snapshot metadata: %w", err)
+	}
+
+	if err := verifyTopLevel(c.rootMeta, metadata.SNAPSHOT, snapshotMeta.Signed.Type, snapshotMeta); err != nil {
+		return fmt.Errorf("failed to verify snapshot metadata: %w", err)
+	}
+
+	if err := checkExpiry(c.expiryPolicy, metadata.SNAPSHOT, snapshotMeta.Signed.Expires, c.now()); err != nil {
+		return fmt.Errorf("failed to load snapshot metadata: %w", err)
+	}
+
+	if err := verifyMetaFile(timestampMeta.Signed.Meta, "snapshot.json", snapshotMeta.Signed.Version, snapshotBytes); err != nil {
+		return fmt.Errorf("snapshot metadata does not match timestamp: %w", err)
 	}
 
 	// Load targets metadata
-	targetsBytes, err := os.ReadFile(filepath.Join(cfg.RepoPath, "targets.json"))
+	targetsBytes, err := readMetadata(metadata.TARGETS, metaVersion(snapshotMeta.Signed.Meta, "targets.json"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read targets metadata: %w", err)
+		return fmt.Errorf("failed to read targets metadata: %w", err)
 	}
 
 	targetsMeta := &metadata.Metadata[metadata.TargetsType]{}
 	if err := json.Unmarshal(targetsBytes, targetsMeta); err != nil {
-		return nil, fmt.Errorf("failed to parse targets metadata: %w", err)
+		return fmt.Errorf("failed to parse targets metadata: %w", err)
 	}
 
-	client := &Client{
-		rootMeta:      rootMeta,
-		targetsMeta:   targetsMeta,
-		delegatedMeta: make(map[string]*metadata.Metadata[metadata.TargetsType]),
+	// Targets must be signed by a threshold of the keys root assigns to it
+	if err := verifyTopLevel(c.rootMeta, metadata.TARGETS, targetsMeta.Signed.Type, targetsMeta); err != nil {
+		return fmt.Errorf("failed to verify targets metadata: %w", err)
 	}
 
-	// Load delegated targets metadata
-	if err := client.loadDelegatedTargets(cfg.RepoPath); err != nil {
-		return nil, fmt.Errorf("failed to load delegated targets: %w", err)
+	if err := checkExpiry(c.expiryPolicy, metadata.TARGETS, targetsMeta.Signed.Expires, c.now()); err != nil {
+		return fmt.Errorf("failed to load targets metadata: %w", err)
 	}
 
-	return client, nil
+	if err := verifyMetaFile(snapshotMeta.Signed.Meta, "targets.json", targetsMeta.Signed.Version, targetsBytes); err != nil {
+		return fmt.Errorf("targets metadata does not match snapshot: %w", err)
+	}
+
+	c.timestampMeta = timestampMeta
+	c.snapshotMeta = snapshotMeta
+	c.targetsMeta = targetsMeta
+
+	return nil
 }
 
-// loadDelegatedTargets loads all delegated targets metadata files
-func (c *Client) loadDelegatedTargets(repoPath string) error {
-	if c.targetsMeta.Signed.Delegations == nil {
-		return nil // No delegations
+// loadDelegatedTargets loads and verifies all delegated targets metadata
+// files, following nested delegations depth-first in delegation order. Every
+// role's delegations are loaded as deep as the shortest route to it allows,
+// as that is how deep lookups may follow them.
+func (c *Client) loadDelegatedTargets(readMetadata metadataReader) error {
+	return c.loadDelegations(readMetadata, metadata.TARGETS, c.targetsMeta, 1)
+}
+
+// loadDelegations loads and verifies the roles delegated by delegator, which
+// sits at the given delegation depth, and recursively their delegations
+func (c *Client) loadDelegations(readMetadata metadataReader, delegatorName string, delegator *metadata.Metadata[metadata.TargetsType], depth int) error {
+	if delegator.Signed.Delegations == nil || depth > c.maxDelegationDepth {
+		return nil
 	}
 
-	for _, role := range c.targetsMeta.Signed.Delegations.Roles {
-		metaFile := filepath.Join(repoPath, role.Name+".json")
-		delegatedBytes, err := os.ReadFile(metaFile)
+	for _, role := range c.delegatedRoles(delegator.Signed.Delegations) {
+		if role.Name == metadata.TARGETS {
+			continue
+		}
+
+		// Each role is loaded once, which also breaks delegation cycles. A
+		// role that several roles delegate to must still be signed by a
+		// threshold of the keys each of them assigns to it, and lookups
+		// following a delegation it is not signed for are denied.
+		if loadedMeta, loaded := c.delegatedMeta[role.Name]; loaded {
+			if err := verifyDelegated(delegator, role, loadedMeta); err != nil {
+				c.delegationUntrusted(delegatorName, role.Name, err)
+				continue
+			}
+
+			// A role first reached through a longer route may delegate
+			// to roles that were too deep to load then
+			if depth < c.delegationDepths[role.Name] {
+				c.delegationDepths[role.Name] = depth
+				if err := c.loadDelegations(readMetadata, role.Name, loadedMeta, depth+1); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if _, failed := c.roleErrors[role.Name]; failed {
+			continue
+		}
+		if _, untrusted := c.delegationErrors[delegation{delegatorName, role.Name}]; untrusted {
+			continue
+		}
+		if len(c.delegatedMeta)+len(c.roleErrors)+len(c.delegationErrors) >= c.maxDelegatedRoles {
+			return fmt.Errorf("metadata delegates to more than %d roles", c.maxDelegatedRoles)
+		}
+
+		// Unavailable roles are recorded rather than skipped, so that
+		// lookups reaching them fail closed
+		delegatedBytes, err := readMetadata(role.Name, metaVersion(c.snapshotMeta.Signed.Meta, role.Name+".json"))
 		if err != nil {
-			// Don't fail if delegated metadata is missing - just log and continue
+			c.roleUnavailable(role.Name, RoleMissing, err)
 			continue
 		}
 
 		delegatedMeta := &metadata.Metadata[metadata.TargetsType]{}
 		if err := json.Unmarshal(delegatedBytes, delegatedMeta); err != nil {
-			return fmt.Errorf("failed to parse delegated metadata %s: %w", role.Name, err)
+			c.roleUnavailable(role.Name, RoleInvalid, err)
+			continue
+		}
+
+		// Delegated metadata must be signed by a threshold of the keys its
+		// delegator assigns to the role. Another delegator of the role may
+		// still load it with the keys it assigns.
+		if err := verifyDelegated(delegator, role, delegatedMeta); err != nil {
+			c.delegationUntrusted(delegatorName, role.Name, err)
+			continue
+		}
+
+		if err := checkExpiry(c.expiryPolicy, role.Name, delegatedMeta.Signed.Expires, c.now()); err != nil {
+			c.roleUnavailable(role.Name, RoleExpired, err)
+			continue
+		}
+
+		if err := verifyMetaFile(c.snapshotMeta.Signed.Meta, role.Name+".json", delegatedMeta.Signed.Version, delegatedBytes); err != nil {
+			c.roleUnavailable(role.Name, RoleInvalid, fmt.Errorf("does not match snapshot: %w", err))
+			continue
+		}
+
+		if err := c.checkRollback(role.Name, delegatedMeta.Signed.Version, role.KeyIDs); err != nil {
+			return fmt.Errorf("failed to load delegated metadata %s: %w", role.Name, err)
 		}
+		c.recordVersion(role.Name, delegatedMeta.Signed.Version, role.KeyIDs)
 
 		c.delegatedMeta[role.Name] = delegatedMeta
+		c.delegationDepths[role.Name] = depth
+		if delegator.Signed.Delegations.SuccinctRoles != nil || len(role.PathHashPrefixes) > 0 {
+			c.hashBinDelegators[role.Name] = delegatorName
+		}
+
+		if err := c.loadDelegations(readMetadata, role.Name, delegatedMeta, depth+1); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// roleVisit is a targets role queued for inspection during path lookup
+type roleVisit struct {
+	// role names the role and the delegation that made it trusted for the
+	// path
+	role  ConsultedRole
+	depth int
+	// chain is the delegation chain from the top-level targets role
+	chain []string
+}
+
 // VerifyPath checks if the given path is allowed according to TUF delegation
 func (c *Client) VerifyPath(path string) (bool, error) {
-	// Normalize path by ensuring it starts with /
+	decision, err := c.Decide(path)
+	if err != nil {
+		return false, err
+	}
+
+	return decision.Allowed, nil
+}
+
+// Decide looks up the given path in the trusted metadata and explains the
+// outcome. Allowed paths are answered from the target index; other paths are
+// searched for in the delegation graph to explain the denial.
+func (c *Client) Decide(path string) (Decision, error) {
+	return c.DecideContext(context.Background(), path)
+}
+
+// DecideContext is Decide tracing the delegation search in child spans of
+// any span in ctx. Index hits skip the search, so they only mark that span
+// as indexed.
+func (c *Client) DecideContext(ctx context.Context, path string) (Decision, error) {
+	path = normalizePath(path)
+
+	decision := Decision{
+		Path:     path,
+		Versions: c.topLevelVersions(),
+	}
+
+	// Expired metadata can no longer vouch for any path
+	if err := c.checkTopLevelExpiry(); err != nil {
+		return decision.deny(DenyMetadataExpired), nil
+	}
+
+	result, indexed := c.index.lookup(path)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("tuf.indexed", indexed))
+	if !indexed {
+		result = c.search(ctx, path)
+	}
+
+	// Every role consulted along the way must still be current
+	decision.Consulted = result.consulted
+	for _, consulted := range result.consulted {
+		roleName := consulted.Role
+		decision.ConsultedRoles = append(decision.ConsultedRoles, roleName)
+
+		roleMeta, exists := c.targetsRole(roleName)
+		if !exists {
+			continue
+		}
+
+		decision.Versions[roleName] = roleMeta.Signed.Version
+		if err := checkExpiry(c.expiryPolicy, roleName, roleMeta.Signed.Expires, c.now()); err != nil {
+			return decision.deny(DenyMetadataExpired), nil
+		}
+	}
+
+	if result.target == nil {
+		return decision.deny(result.reason), nil
+	}
+
+	decision.Allowed = true
+	decision.RoleChain = result.chain
+	decision.Target = newTargetInfo(result.target)
+
+	return decision, nil
+}
+
+// normalizePath ensures path starts with /
+func normalizePath(path string) string {
 	if !strings.HasPrefix(path, "/") {
-		path = "/" + path
+		return "/" + path
 	}
 
-	// First check top-level targets
-	if c.hasTargetInMetadata(c.targetsMeta, path) {
-		return true, nil
+	return path
+}
+
+// lookupResult is the outcome of searching the delegation graph for a path,
+// before metadata expiry is taken into account
+type lookupResult struct {
+	// consulted lists the roles searched, in search order
+	consulted []ConsultedRole
+	// chain and target are set when a role lists the path
+	chain  []string
+	target *metadata.TargetFiles
+	// reason explains why no role lists the path
+	reason DenyReason
+}
+
+// clone returns a copy of r that shares no slices with it. The target file
+// is not copied, as Decide converts it into a new TargetInfo.
+func (r lookupResult) clone() lookupResult {
+	r.chain = slices.Clone(r.chain)
+	r.consulted = slices.Clone(r.consulted)
+	for i := range r.consulted {
+		r.consulted[i].Paths = slices.Clone(r.consulted[i].Paths)
+		r.consulted[i].PathHashPrefixes = slices.Clone(r.consulted[i].PathHashPrefixes)
 	}
 
-	// Check delegated targets if they exist
-	if c.targetsMeta.Signed.Delegations != nil {
-		for _, role := range c.targetsMeta.Signed.Delegations.Roles {
-			// Check if path matches delegation patterns
-			if c.pathMatchesDelegation(path, role.Paths) {
-				// Check if we have the delegated metadata
-				if delegatedMeta, exists := c.delegatedMeta[role.Name]; exists {
-					if c.hasTargetInMetadata(delegatedMeta, path) {
-						return true, nil
-					}
-				}
-			}
+	return r
+}
+
+// search looks for path in the delegation graph. Roles are searched in
+// pre-order depth-first order starting at the top-level targets role, as
+// described in the TUF specification. Each role consulted is traced in a
+// child span of any span in ctx.
+func (c *Client) search(ctx context.Context, path string) lookupResult {
+	var result lookupResult
+
+	toVisit := []roleVisit{{role: ConsultedRole{Role: metadata.TARGETS}, chain: []string{metadata.TARGETS}}}
+	visited := make(map[string]bool)
+	matchedDelegation := false
+
+	for len(toVisit) > 0 && len(visited) < c.maxDelegations {
+		// Pop the next role off the stack
+		current := toVisit[len(toVisit)-1]
+		toVisit = toVisit[:len(toVisit)-1]
+
+		// Skip roles already searched to break delegation cycles
+		if visited[current.role.Role] {
+			continue
 		}
+
+		result.consulted = append(result.consulted, current.role)
+		span := traceRole(ctx, current)
+
+		// A role trusted for the path that cannot be consulted might own
+		// it, so lower-priority roles must not decide in its place
+		roleMeta, exists := c.targetsRole(current.role.Role)
+		_, untrusted := c.delegationErrors[delegation{current.role.Delegator, current.role.Role}]
+		if !exists || untrusted {
+			span.SetStatus(codes.Error, "role unavailable")
+			span.End()
+			result.reason = c.unavailableReason(current.role.Role)
+			return result
+		}
+
+		targetFile, listed := roleMeta.Signed.Targets[path]
+		span.SetAttributes(attribute.Bool("tuf.lists_target", listed))
+		if listed {
+			span.End()
+			result.chain = current.chain
+			result.target = targetFile
+			return result
+		}
+		visited[current.role.Role] = true
+
+		if roleMeta.Signed.Delegations == nil || current.depth >= c.maxDelegationDepth {
+			span.End()
+			continue
+		}
+
+		// A matching terminating role owns the path: roles still waiting to
+		// be backtracked to are never consulted, even if it lacks the target
+		children, terminating := c.matchingChildren(current.role.Role, roleMeta.Signed.Delegations, path, current.depth+1)
+		span.SetAttributes(attribute.Int("tuf.matching_delegations", len(children)))
+		span.End()
+		if terminating {
+			toVisit = nil
+		}
+		if len(children) > 0 {
+			matchedDelegation = true
+		}
+
+		// Push children in reverse so they are popped in delegation order
+		for i := len(children) - 1; i >= 0; i-- {
+			child := children[i]
+			child.chain = append(slices.Clip(current.chain), child.role.Role)
+			toVisit = append(toVisit, child)
+		}
+	}
+
+	switch {
+	case len(toVisit) > 0:
+		result.reason = DenyDelegationLimit
+	case matchedDelegation:
+		result.reason = DenyTargetNotFound
+	default:
+		result.reason = DenyNoMatchingDelegation
 	}
 
-	return false, nil
+	return result
 }
 
-// hasTargetInMetadata checks if a target path exists in the given metadata
-func (c *Client) hasTargetInMetadata(meta *metadata.Metadata[metadata.TargetsType], path string) bool {
-	_, exists := meta.Signed.Targets[path]
-	return exists
+// unavailableReason returns the reason to deny a path owned by a role that
+// cannot be consulted. A role whose metadata had expired when loaded is
+// denied as expired, as it is once loaded metadata expires.
+func (c *Client) unavailableReason(roleName string) DenyReason {
+	if roleErr, ok := c.roleErrors[roleName]; ok && roleErr.State == RoleExpired {
+		return DenyMetadataExpired
+	}
+
+	return DenyRoleUnavailable
 }
 
-// pathMatchesDelegation checks if a path matches any of the delegation patterns
-func (c *Client) pathMatchesDelegation(path string, patterns []string) bool {
-	for _, pattern := range patterns {
-		if c.matchPattern(path, pattern) {
-			return true
+// matchingChildren returns the roles that delegator delegates path to in
+// delegation order, and whether the search must stop at them because one is
+// terminating. Earlier roles take priority, so roles after a terminating one
+// are dropped.
+func (c *Client) matchingChildren(delegator string, delegations *metadata.Delegations, path string, depth int) ([]roleVisit, bool) {
+	// Succinct hash bins are terminating and each path hashes into one bin
+	if delegations.SuccinctRoles != nil {
+		if !validBitLength(delegations.SuccinctRoles) {
+			return nil, true
+		}
+
+		var children []roleVisit
+		for _, bin := range delegations.SuccinctRoles.GetRolesForTarget(path) {
+			children = append(children, roleVisit{role: ConsultedRole{
+				Role:              bin.Name,
+				Delegator:         delegator,
+				SuccinctBitLength: delegations.SuccinctRoles.BitLength,
+			}, depth: depth})
+		}
+		return children, true
+	}
+
+	var children []roleVisit
+	for _, i := range c.candidateRoles(delegator, delegations, path) {
+		role := delegations.Roles[i]
+		consulted, ok := c.matchDelegation(delegator, role, path)
+		if !ok {
+			continue
+		}
+		children = append(children, roleVisit{role: consulted, depth: depth})
+		if role.Terminating {
+			return children, true
 		}
 	}
-	return false
+
+	return children, false
 }
 
-// matchPattern performs simple pattern matching (supports * wildcard)
-func (c *Client) matchPattern(path, pattern string) bool {
-	// Simple wildcard matching - in production you'd want more sophisticated pattern matching
-	if strings.HasSuffix(pattern, "*") {
-		prefix := strings.TrimSuffix(pattern, "*")
-		return strings.HasPrefix(path, prefix)
+// candidateRoles returns the indices, in delegation order, of the roles
+// delegator may delegate path to, narrowed down by its pattern index
+func (c *Client) candidateRoles(delegator string, delegations *metadata.Delegations, path string) []int {
+	if index, ok := c.patterns[delegator]; ok {
+		return index.candidates(path)
+	}
+
+	candidates := make([]int, len(delegations.Roles))
+	for i := range candidates {
+		candidates[i] = i
 	}
 
-	return path == pattern
+	return candidates
 }
 
-// GetAllowedPaths returns a list of all paths that are allowed by the TUF metadata
-func (c *Client) GetAllowedPaths() ([]string, error) {
-	var paths []string
+// targetsRole returns the trusted metadata for a targets role by name
+func (c *Client) targetsRole(name string) (*metadata.Metadata[metadata.TargetsType], bool) {
+	if name == metadata.TARGETS {
+		return c.targetsMeta, true
+	}
 
-	// Get paths from top-level targets
-	for path := range c.targetsMeta.Signed.Targets {
-		paths = append(paths, path)
+	roleMeta, exists := c.delegatedMeta[name]
+	return roleMeta, exists
+}
+
+// matchDelegation checks if delegator's delegated role is trusted for the
+// given path, either through its path patterns or its path hash prefixes, and
+// returns the patterns or prefixes that match
+func (c *Client) matchDelegation(delegator string, role metadata.DelegatedRole, path string) (ConsultedRole, bool) {
+	consulted := ConsultedRole{Role: role.Name, Delegator: delegator}
+	if len(role.Paths) > 0 {
+		consulted.Paths = c.matchingPatterns(path, role.Paths)
+	} else {
+		consulted.PathHashPrefixes = matchingHashPrefixes(path, role.PathHashPrefixes)
 	}
 
-	// Get paths from delegated targets
-	for _, delegatedMeta := range c.delegatedMeta {
-		for path := range delegatedMeta.Signed.Targets {
-			paths = append(paths, path)
+	return consulted, len(consulted.Paths) > 0 || len(consulted.PathHashPrefixes) > 0
+}
+
+// matchingHashPrefixes returns the prefixes that the hex-encoded SHA-256
+// digest of the path starts with, as used by hash bin delegations
+func matchingHashPrefixes(path string, prefixes []string) []string {
+	if len(prefixes) == 0 {
+		return nil
+	}
+
+	digest := sha256.Sum256([]byte(path))
+	pathHash := hex.EncodeToString(digest[:])
+
+	var matches []string
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(pathHash, strings.ToLower(prefix)) {
+			matches = append(matches, prefix)
+		}
+	}
+	return matches
+}
+
+// matchingPatterns returns the delegation patterns that a path matches
+func (c *Client) matchingPatterns(path string, patterns []string) []string {
+	var matches []string
+	for _, pattern := range patterns {
+		if c.matchPattern(path, pattern) {
+			matches = append(matches, pattern)
+		}
+	}
+	return matches
+}
+
+// matchPattern matches a path against a delegated path pattern using TUF
+// shell-style wildcards: "*" matches any sequence of characters within a
+// single path segment, "?" matches one character and "[...]" matches a
+// character class. Wildcards never match across "/", so a pattern matches
+// only paths with the same number of segments.
+func (c *Client) matchPattern(path, pattern string) bool {
+	pathParts := strings.Split(path, "/")
+	patternParts := strings.Split(pattern, "/")
+	if len(pathParts) != len(patternParts) {
+		return false
+	}
+
+	for i := range patternParts {
+		// Malformed patterns never match
+		if ok, err := gopath.Match(patternParts[i], pathParts[i]); err != nil || !ok {
+			return false
 		}
 	}
 
-	return paths, nil
+	return true
 }
 
-// GetDelegationInfo returns information about the delegations
+// GetAllowedPaths returns the target paths the delegation graph allows,
+// sorted. Targets listed by a role outside its delegated paths or hash bin,
+// or hidden by an earlier terminating delegation, are left out. Paths are
+// listed regardless of expiry, which Decide checks when a path is looked up.
+func (c *Client) GetAllowedPaths() ([]string, error) {
+	return c.index.paths(), nil
+}
+
+// GetDelegationInfo returns the delegated path patterns of every role,
+// including roles delegated by other delegated roles. Succinct hash bin
+// delegations have no path patterns and are reported by the SuccinctRoles
+// field of the delegating role's status instead.
 func (c *Client) GetDelegationInfo() map[string][]string {
 	delegations := make(map[string][]string)
 
-	if c.targetsMeta.Signed.Delegations != nil {
-		for _, role := range c.targetsMeta.Signed.Delegations.Roles {
+	addDelegations := func(meta *metadata.Metadata[metadata.TargetsType]) {
+		if meta.Signed.Delegations == nil {
+			return
+		}
+		for _, role := range meta.Signed.Delegations.Roles {
 			delegations[role.Name] = role.Paths
 		}
 	}
 
+	addDelegations(c.targetsMeta)
+	for _, delegatedMeta := range c.delegatedMeta {
+		addDelegations(delegatedMeta)
+	}
+
 	return delegations
 }
 
@@ -195,6 +890,29 @@ func (c *Client) Close() error {
 
 // ValidateConfig checks if the provided configuration is valid
 func ValidateConfig(cfg Config) error {
+	if cfg.RefreshInterval < 0 {
+		return fmt.Errorf("RefreshInterval must not be negative")
+	}
+	if cfg.RefreshJitter < 0 || cfg.RefreshJitter > 1 {
+		return fmt.Errorf("RefreshJitter must be between 0 and 1")
+	}
+	if cfg.DecisionCacheSize < 0 || cfg.DecisionCacheTTL < 0 {
+		return fmt.Errorf("DecisionCacheSize and DecisionCacheTTL must not be negative")
+	}
+	if cfg.ReloadDebounce < 0 || cfg.PollInterval < 0 {
+		return fmt.Errorf("ReloadDebounce and PollInterval must not be negative")
+	}
+
+	if cfg.MetadataURL != "" {
+		if len(cfg.RootBytes) == 0 {
+			return fmt.Errorf("RootBytes is required with MetadataURL")
+		}
+		if _, err := url.ParseRequestURI(cfg.MetadataURL); err != nil {
+			return fmt.Errorf("invalid MetadataURL: %w", err)
+		}
+		return nil
+	}
+
 	if cfg.RepoPath == "" {
 		return fmt.Errorf("RepoPath is required")
 	}
@@ -204,7 +922,11 @@ func ValidateConfig(cfg Config) error {
 		return fmt.Errorf("repository directory does not exist: %s", cfg.RepoPath)
 	}
 
-	// Check if root metadata file exists
+	// Check if root metadata file exists when no root is supplied directly
+	if len(cfg.RootBytes) > 0 {
+		return nil
+	}
+
 	rootPath := filepath.Join(cfg.RepoPath, "root.json")
 	if _, err := os.Stat(rootPath); os.IsNotExist(err) {
 		return fmt.Errorf("root metadata file does not exist: %s", rootPath)
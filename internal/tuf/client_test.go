@@ -0,0 +1,402 @@
+package tuf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+func TestSharedRoleVerifiedForEachDelegator(t *testing.T) {
+	repo := newTestRepo(t, time.Now().Add(24*time.Hour))
+	repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "team-a", Paths: []string{"/a/*"}})
+	repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "team-b", Paths: []string{"/b/*"}})
+	repo.delegate("team-a", metadata.DelegatedRole{Name: "shared", Paths: []string{"/a/*"}})
+	repo.delegate("team-b", metadata.DelegatedRole{Name: "shared", Paths: []string{"/b/*"}})
+	repo.addTarget("shared", "/b/image")
+
+	// team-b trusts a key that never signed shared
+	otherKey := repo.key("other")
+	delegations := repo.targets["team-b"].Signed.Delegations
+	delegations.Keys[otherKey.ID()] = otherKey
+	delegations.Roles[0].KeyIDs = []string{otherKey.ID()}
+
+	repo.addTarget("shared", "/a/image")
+
+	dir := t.TempDir()
+	repo.write(dir)
+
+	_, err := NewClient(Config{RepoPath: dir, StrictDelegations: true})
+	if !errors.Is(err, ErrRoleUnavailable) || !strings.Contains(err.Error(), "shared delegated by team-b") {
+		t.Fatalf("NewClient() error = %v, want shared rejected for team-b", err)
+	}
+
+	client, err := NewClient(Config{RepoPath: dir})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	loadErrors := client.LoadErrors()
+	if len(loadErrors) != 1 || loadErrors[0].Role != "shared" || loadErrors[0].Delegator != "team-b" || loadErrors[0].State != RoleInvalid {
+		t.Errorf("LoadErrors() = %v, want shared invalid for team-b", loadErrors)
+	}
+
+	tests := []struct {
+		path    string
+		allowed bool
+		reason  DenyReason
+	}{
+		{path: "/a/image", allowed: true},
+		{path: "/b/image", reason: DenyRoleUnavailable},
+	}
+	for _, tt := range tests {
+		decision, err := client.Decide(tt.path)
+		if err != nil {
+			t.Fatalf("Decide(%q) error = %v", tt.path, err)
+		}
+		if decision.Allowed != tt.allowed || decision.Reason != tt.reason {
+			t.Errorf("Decide(%q) allowed = %v, reason = %q, want %v, %q", tt.path, decision.Allowed, decision.Reason, tt.allowed, tt.reason)
+		}
+	}
+}
+
+func TestBadSignatureReportedAsInvalidRole(t *testing.T) {
+	repo := newTestRepo(t, time.Now().Add(24*time.Hour))
+	repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "signed", Paths: []string{"/signed/*"}})
+	repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "unsigned", Paths: []string{"/unsigned/*"}})
+	repo.addTarget("signed", "/signed/image")
+	repo.addTarget("unsigned", "/unsigned/image")
+
+	// targets trusts a key for unsigned that never signed it
+	otherKey := repo.key("other")
+	delegations := repo.targets[metadata.TARGETS].Signed.Delegations
+	delegations.Keys[otherKey.ID()] = otherKey
+	delegations.Roles[1].KeyIDs = []string{otherKey.ID()}
+
+	dir := t.TempDir()
+	repo.write(dir)
+
+	if _, err := NewClient(Config{RepoPath: dir, StrictDelegations: true}); !errors.Is(err, ErrRoleUnavailable) {
+		t.Fatalf("NewClient() error = %v, want %v in strict mode", err, ErrRoleUnavailable)
+	}
+
+	client, err := NewClient(Config{RepoPath: dir})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	status := client.GetRoleStatus()["unsigned"]
+	if status.State != RoleInvalid || status.Expires != nil || status.Error == "" {
+		t.Errorf("unsigned status = %+v, want invalid with an error and no expiry", status)
+	}
+	if status := client.GetRoleStatus()["signed"]; status.State != RoleLoaded || status.Expires == nil {
+		t.Errorf("signed status = %+v, want loaded with an expiry", status)
+	}
+
+	decision, err := client.Decide("/unsigned/image")
+	if err != nil {
+		t.Fatalf("Decide() error = %v", err)
+	}
+	if decision.Allowed || decision.Reason != DenyRoleUnavailable {
+		t.Errorf("Decide() allowed = %v, reason = %q, want denied with %q", decision.Allowed, decision.Reason, DenyRoleUnavailable)
+	}
+	if allowed, err := client.VerifyPath("/signed/image"); err != nil || !allowed {
+		t.Errorf("VerifyPath() = %v, %v, want allowed", allowed, err)
+	}
+}
+
+func TestDecideDelegationPriority(t *testing.T) {
+	type delegation struct {
+		delegator string
+		role      metadata.DelegatedRole
+	}
+	tests := []struct {
+		name        string
+		delegations []delegation
+		targets     map[string]string
+		path        string
+		chain       []string
+		reason      DenyReason
+		consulted   []string
+	}{
+		{
+			name: "earlier role wins",
+			delegations: []delegation{
+				{metadata.TARGETS, metadata.DelegatedRole{Name: "first", Paths: []string{"/a/*"}}},
+				{metadata.TARGETS, metadata.DelegatedRole{Name: "second", Paths: []string{"/a/*"}}},
+			},
+			targets:   map[string]string{"first": "/a/image", "second": "/a/image"},
+			path:      "/a/image",
+			chain:     []string{metadata.TARGETS, "first"},
+			consulted: []string{metadata.TARGETS, "first"},
+		},
+		{
+			name: "later role searched after non-terminating role",
+			delegations: []delegation{
+				{metadata.TARGETS, metadata.DelegatedRole{Name: "first", Paths: []string{"/a/*"}}},
+				{metadata.TARGETS, metadata.DelegatedRole{Name: "second", Paths: []string{"/a/*"}}},
+			},
+			targets:   map[string]string{"second": "/a/image"},
+			path:      "/a/image",
+			chain:     []string{metadata.TARGETS, "second"},
+			consulted: []string{metadata.TARGETS, "first", "second"},
+		},
+		{
+			name: "terminating role hides later role",
+			delegations: []delegation{
+				{metadata.TARGETS, metadata.DelegatedRole{Name: "first", Paths: []string{"/a/*"}, Terminating: true}},
+				{metadata.TARGETS, metadata.DelegatedRole{Name: "second", Paths: []string{"/a/*"}}},
+			},
+			targets:   map[string]string{"second": "/a/image"},
+			path:      "/a/image",
+			reason:    DenyTargetNotFound,
+			consulted: []string{metadata.TARGETS, "first"},
+		},
+		{
+			name: "nested terminating role stops backtracking",
+			delegations: []delegation{
+				{metadata.TARGETS, metadata.DelegatedRole{Name: "outer", Paths: []string{"/a/*"}}},
+				{metadata.TARGETS, metadata.DelegatedRole{Name: "later", Paths: []string{"/a/*"}}},
+				{"outer", metadata.DelegatedRole{Name: "inner", Paths: []string{"/a/*"}, Terminating: true}},
+			},
+			targets:   map[string]string{"later": "/a/image"},
+			path:      "/a/image",
+			reason:    DenyTargetNotFound,
+			consulted: []string{metadata.TARGETS, "outer", "inner"},
+		},
+		{
+			name: "terminating role for other paths",
+			delegations: []delegation{
+				{metadata.TARGETS, metadata.DelegatedRole{Name: "first", Paths: []string{"/b/*"}, Terminating: true}},
+				{metadata.TARGETS, metadata.DelegatedRole{Name: "second", Paths: []string{"/a/*"}}},
+			},
+			targets:   map[string]string{"second": "/a/image"},
+			path:      "/a/image",
+			chain:     []string{metadata.TARGETS, "second"},
+			consulted: []string{metadata.TARGETS, "second"},
+		},
+		{
+			name: "target outside the role's patterns",
+			delegations: []delegation{
+				{metadata.TARGETS, metadata.DelegatedRole{Name: "narrow", Paths: []string{"/a/*"}}},
+			},
+			targets:   map[string]string{"narrow": "/b/image"},
+			path:      "/b/image",
+			reason:    DenyNoMatchingDelegation,
+			consulted: []string{metadata.TARGETS},
+		},
+		{
+			name: "target outside the patterns of a nested role",
+			delegations: []delegation{
+				{metadata.TARGETS, metadata.DelegatedRole{Name: "outer", Paths: []string{"/a/*"}}},
+				{"outer", metadata.DelegatedRole{Name: "inner", Paths: []string{"/a/inner-*"}}},
+			},
+			targets:   map[string]string{"inner": "/a/image"},
+			path:      "/a/image",
+			reason:    DenyTargetNotFound,
+			consulted: []string{metadata.TARGETS, "outer"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepo(t, time.Now().Add(24*time.Hour))
+			for _, d := range tt.delegations {
+				repo.delegate(d.delegator, d.role)
+			}
+			for roleName, path := range tt.targets {
+				repo.addTarget(roleName, path)
+			}
+
+			dir := t.TempDir()
+			repo.write(dir)
+
+			client, err := NewClient(Config{RepoPath: dir})
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+
+			decision, err := client.Decide(tt.path)
+			if err != nil {
+				t.Fatalf("Decide() error = %v", err)
+			}
+			if allowed := tt.chain != nil; decision.Allowed != allowed || decision.Reason != tt.reason {
+				t.Errorf("Decide() allowed = %v, reason = %q, want %v, %q", decision.Allowed, decision.Reason, allowed, tt.reason)
+			}
+			if !slices.Equal(decision.RoleChain, tt.chain) {
+				t.Errorf("Decide() role chain = %v, want %v", decision.RoleChain, tt.chain)
+			}
+			if !slices.Equal(decision.ConsultedRoles, tt.consulted) {
+				t.Errorf("Decide() consulted roles = %v, want %v", decision.ConsultedRoles, tt.consulted)
+			}
+		})
+	}
+}
+
+func TestMaxDelegationsBoundsVisitedRoles(t *testing.T) {
+	repo := newTestRepo(t, time.Now().Add(24*time.Hour))
+	repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "a", Paths: []string{"/*"}})
+	repo.delegate("a", metadata.DelegatedRole{Name: "b", Paths: []string{"/*"}})
+	repo.addTarget("b", "/image")
+
+	dir := t.TempDir()
+	repo.write(dir)
+
+	tests := []struct {
+		maxDelegations int
+		allowed        bool
+		consulted      []string
+	}{
+		{maxDelegations: 2, allowed: false, consulted: []string{metadata.TARGETS, "a"}},
+		{maxDelegations: 3, allowed: true, consulted: []string{metadata.TARGETS, "a", "b"}},
+	}
+
+	for _, tt := range tests {
+		client, err := NewClient(Config{RepoPath: dir, MaxDelegations: tt.maxDelegations})
+		if err != nil {
+			t.Fatalf("NewClient() error = %v", err)
+		}
+
+		decision, err := client.Decide("/image")
+		if err != nil {
+			t.Fatalf("Decide() error = %v", err)
+		}
+		if decision.Allowed != tt.allowed {
+			t.Errorf("MaxDelegations %d: Decide() allowed = %v, want %v", tt.maxDelegations, decision.Allowed, tt.allowed)
+		}
+		if !tt.allowed && decision.Reason != DenyDelegationLimit {
+			t.Errorf("MaxDelegations %d: Decide() reason = %q, want %q", tt.maxDelegations, decision.Reason, DenyDelegationLimit)
+		}
+		if !slices.Equal(decision.ConsultedRoles, tt.consulted) {
+			t.Errorf("MaxDelegations %d: Decide() consulted %v, want %v", tt.maxDelegations, decision.ConsultedRoles, tt.consulted)
+		}
+	}
+}
+
+func TestDecisionDescribesConsultedDelegations(t *testing.T) {
+	digest := sha256.Sum256([]byte("/image"))
+	hashPrefix := hex.EncodeToString(digest[:])[:2]
+
+	repo := newTestRepo(t, time.Now().Add(24*time.Hour))
+	repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "by-path", Paths: []string{"/other", "/ima?e", "/*"}})
+	repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "by-hash", PathHashPrefixes: []string{hashPrefix, "zz"}})
+	succinct := repo.delegateSuccinct("by-hash", "bins", 1)
+	bin := succinct.GetRolesForTarget("/image")[0].Name
+	repo.addTarget(bin, "/image")
+
+	dir := t.TempDir()
+	repo.write(dir)
+
+	client, err := NewClient(Config{RepoPath: dir})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	decision, err := client.Decide("/image")
+	if err != nil {
+		t.Fatalf("Decide() error = %v", err)
+	}
+	want := []ConsultedRole{
+		{Role: metadata.TARGETS},
+		{Role: "by-path", Delegator: metadata.TARGETS, Paths: []string{"/ima?e", "/*"}},
+		{Role: "by-hash", Delegator: metadata.TARGETS, PathHashPrefixes: []string{hashPrefix}},
+		{Role: bin, Delegator: "by-hash", SuccinctBitLength: 1},
+	}
+	if !decision.Allowed {
+		t.Fatalf("Decide() denied with reason %q, want allowed", decision.Reason)
+	}
+	if !reflect.DeepEqual(decision.Consulted, want) {
+		t.Errorf("Decide() consulted = %+v, want %+v", decision.Consulted, want)
+	}
+	if !slices.Equal(decision.ConsultedRoles, []string{metadata.TARGETS, "by-path", "by-hash", bin}) {
+		t.Errorf("Decide() consulted roles = %v, want the roles of %+v", decision.ConsultedRoles, want)
+	}
+}
+
+func TestDelegationsLoadedToShortestRouteDepth(t *testing.T) {
+	repo := newTestRepo(t, time.Now().Add(24*time.Hour))
+	repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "x", Paths: []string{"/x/*"}})
+	repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "d", Paths: []string{"/e/*"}})
+	repo.delegate("x", metadata.DelegatedRole{Name: "d", Paths: []string{"/x/*"}})
+	repo.delegate("d", metadata.DelegatedRole{Name: "e", Paths: []string{"/e/*"}})
+	repo.addTarget("e", "/e/image")
+
+	dir := t.TempDir()
+	repo.write(dir)
+
+	// d is first reached through x, at a depth its delegation to e exceeds
+	client, err := NewClient(Config{RepoPath: dir, MaxDelegationDepth: 2})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if loadErrors := client.LoadErrors(); len(loadErrors) != 0 {
+		t.Errorf("LoadErrors() = %v, want none", loadErrors)
+	}
+
+	decision, err := client.Decide("/e/image")
+	if err != nil {
+		t.Fatalf("Decide() error = %v", err)
+	}
+	if !decision.Allowed {
+		t.Fatalf("Decide() denied with reason %q, want allowed", decision.Reason)
+	}
+	if want := []string{metadata.TARGETS, "d", "e"}; !slices.Equal(decision.RoleChain, want) {
+		t.Errorf("Decide() role chain = %v, want %v", decision.RoleChain, want)
+	}
+}
+
+func TestMaxDelegatedRolesBoundsLoading(t *testing.T) {
+	repo := newTestRepo(t, time.Now().Add(24*time.Hour))
+	for _, roleName := range []string{"a", "b", "c"} {
+		repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: roleName, Paths: []string{"/" + roleName + "/*"}})
+	}
+
+	dir := t.TempDir()
+	repo.write(dir)
+
+	if _, err := NewClient(Config{RepoPath: dir, MaxDelegatedRoles: 2}); err == nil {
+		t.Error("NewClient() loaded 3 delegated roles with MaxDelegatedRoles 2")
+	}
+	if _, err := NewClient(Config{RepoPath: dir, MaxDelegatedRoles: 3}); err != nil {
+		t.Errorf("NewClient() error = %v with MaxDelegatedRoles 3", err)
+	}
+}
+
+func FuzzMatchPattern(f *testing.F) {
+	for _, seed := range []struct{ path, pattern string }{
+		{"/v2/library/alpine/manifests/latest", "/v2/*/manifests/*"},
+		{"/v2/library/alpine/manifests/latest", "/v2/library/*/manifests/?atest"},
+		{"/v2/library/alpine/manifests/latest", "/v2/library/[a-z]*/manifests/latest"},
+		{"/v2/library/alpine/manifests/latest", "/v2/library/*"},
+		{"/v2/library/a", "/v2/*"},
+		{"/v2/library/a", "/v2/[^/]"},
+		{"/v2/lib", "/v2/lib[\\"},
+	} {
+		f.Add(seed.path, seed.pattern)
+	}
+
+	client := &Client{}
+	f.Fuzz(func(t *testing.T, path, pattern string) {
+		role := metadata.DelegatedRole{Name: "fuzz", Paths: []string{pattern}}
+		want, err := role.IsDelegatedPath(path)
+		if err != nil {
+			t.Fatalf("IsDelegatedPath(%q) error = %v", path, err)
+		}
+
+		if got := client.matchPattern(path, pattern); got != want {
+			t.Errorf("matchPattern(%q, %q) = %v, IsDelegatedPath = %v", path, pattern, got, want)
+		}
+
+		// The pattern index must find exactly the matching role
+		candidates := newPatternIndex([]metadata.DelegatedRole{role}).candidates(path)
+		if got := len(candidates) == 1; got != want {
+			t.Errorf("pattern index candidates for %q with pattern %q = %v, IsDelegatedPath = %v", path, pattern, candidates, want)
+		}
+	})
+}
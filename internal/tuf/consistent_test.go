@@ -0,0 +1,94 @@
+package tuf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+// useVersionedNames renames the snapshot and targets metadata in dir to the
+// "<version>.<role>.json" names of a consistent snapshot repository, so that
+// a client reading the unversioned names fails
+func useVersionedNames(t *testing.T, repo *testRepo, dir string) {
+	t.Helper()
+
+	versions := map[string]int64{metadata.SNAPSHOT: repo.snapshot.Signed.Version}
+	for roleName, meta := range repo.targets {
+		versions[roleName] = meta.Signed.Version
+	}
+
+	for roleName, version := range versions {
+		oldPath := filepath.Join(dir, roleName+".json")
+		newPath := filepath.Join(dir, fmt.Sprintf("%d.%s.json", version, roleName))
+		if err := os.Rename(oldPath, newPath); err != nil {
+			t.Fatalf("failed to rename %s metadata: %v", roleName, err)
+		}
+	}
+}
+
+func TestConsistentSnapshotLocalRepository(t *testing.T) {
+	repo := newTestRepo(t, time.Now().Add(24*time.Hour))
+	repo.root.Signed.ConsistentSnapshot = true
+	repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "library", Paths: []string{"/v2/library/*"}})
+	repo.addTarget("library", "/v2/library/alpine")
+	repo.snapshot.Signed.Version = 4
+	repo.targets[metadata.TARGETS].Signed.Version = 3
+	repo.targets["library"].Signed.Version = 2
+
+	dir := t.TempDir()
+	repo.write(dir)
+	rootBytes, err := os.ReadFile(filepath.Join(dir, "root.json"))
+	if err != nil {
+		t.Fatalf("failed to read root: %v", err)
+	}
+
+	// Targets signed by a key only root version 2 trusts can only verify
+	// when the client walks the chain from version 1 to 2.root.json
+	rotateTargetsKey(t, repo, dir)
+	repo.write(dir)
+	useVersionedNames(t, repo, dir)
+
+	client, err := NewClient(Config{RepoPath: dir, RootBytes: rootBytes, StrictDelegations: true})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	rootInfo := client.GetRootInfo()
+	if rootInfo.Version != 2 || !rootInfo.ConsistentSnapshot {
+		t.Errorf("GetRootInfo() version = %d, consistent snapshot = %v, want 2, true", rootInfo.Version, rootInfo.ConsistentSnapshot)
+	}
+
+	decision, err := client.Decide("/v2/library/alpine")
+	if err != nil {
+		t.Fatalf("Decide() error = %v", err)
+	}
+	if !decision.Allowed {
+		t.Fatalf("Decide() denied with reason %q, want allowed", decision.Reason)
+	}
+	if want := []string{metadata.TARGETS, "library"}; !slices.Equal(decision.RoleChain, want) {
+		t.Errorf("Decide() role chain = %v, want %v", decision.RoleChain, want)
+	}
+
+	// Target files of a consistent snapshot repository are stored as
+	// "<hash>.<name>", but targets are looked up by their listed path
+	digest := sha256.Sum256([]byte("/v2/library/alpine"))
+	hash := hex.EncodeToString(digest[:])
+	if decision.Target == nil || decision.Target.Hashes["sha256"] != hash {
+		t.Errorf("Decide() target = %+v, want sha256 %s", decision.Target, hash)
+	}
+
+	decision, err = client.Decide("/v2/library/" + hash + ".alpine")
+	if err != nil {
+		t.Fatalf("Decide() error = %v", err)
+	}
+	if decision.Allowed || decision.Reason != DenyTargetNotFound {
+		t.Errorf("Decide() hash-prefixed name allowed = %v, reason = %q, want denied with %q", decision.Allowed, decision.Reason, DenyTargetNotFound)
+	}
+}
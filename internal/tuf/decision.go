@@ -0,0 +1,158 @@
+package tuf
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+// DenyReason is a machine-readable code explaining why a path was denied
+type DenyReason string
+
+const (
+	// DenyMetadataExpired means metadata needed for the decision has expired
+	DenyMetadataExpired DenyReason = "metadata_expired"
+	// DenyNoMatchingDelegation means the path is not a top-level target and
+	// no delegated role is trusted for it
+	DenyNoMatchingDelegation DenyReason = "no_matching_delegation"
+	// DenyTargetNotFound means roles trusted for the path were consulted but
+	// none of them lists it as a target
+	DenyTargetNotFound DenyReason = "target_not_found"
+	// DenyDelegationLimit means the lookup stopped at the configured bound on
+	// visited roles before finding the path
+	DenyDelegationLimit DenyReason = "delegation_limit"
+	// DenyRoleUnavailable means a role trusted for the path could not be
+	// consulted because its metadata is missing or invalid
+	DenyRoleUnavailable DenyReason = "role_unavailable"
+	// DenyRefreshFailed means the last metadata refresh failed and the
+	// FailClosed policy denies every path until a refresh succeeds
+	DenyRefreshFailed DenyReason = "refresh_failed"
+)
+
+// DecisionRequest describes a request to authorize. Decisions depend only on
+// Path; Method and Host only partition the decision cache when
+// Config.DecisionCacheKeyMethod and Config.DecisionCacheKeyHost are set.
+type DecisionRequest struct {
+	Path   string
+	Method string
+	Host   string
+}
+
+// Decision is the result of looking up a path in the trusted metadata
+type Decision struct {
+	// Path is the normalized path that was looked up
+	Path string `json:"path"`
+
+	// Allowed reports whether a trusted role lists the path as a target
+	Allowed bool `json:"allowed"`
+
+	// Reason explains a denial. It is empty when the path is allowed.
+	Reason DenyReason `json:"reason,omitempty"`
+
+	// RoleChain is the delegation chain from the top-level targets role to
+	// the role that lists the path, when it is allowed
+	RoleChain []string `json:"role_chain,omitempty"`
+
+	// ConsultedRoles lists the roles searched for the path, in search order
+	ConsultedRoles []string `json:"consulted_roles"`
+
+	// Consulted describes each role in ConsultedRoles, in the same order,
+	// with the delegation that made it trusted for the path
+	Consulted []ConsultedRole `json:"consulted"`
+
+	// Target describes the target file when the path is allowed
+	Target *TargetInfo `json:"target,omitempty"`
+
+	// Versions holds the version of the top-level metadata and of every
+	// consulted role, keyed by role name
+	Versions map[string]int64 `json:"versions"`
+}
+
+// ConsultedRole describes a role searched for a path and why it was trusted
+// for the path. Exactly one of Paths, PathHashPrefixes and SuccinctBitLength
+// is set for delegated roles.
+type ConsultedRole struct {
+	// Role is the name of the consulted role
+	Role string `json:"role"`
+
+	// Delegator is the role that delegated the path to Role. It is empty for
+	// the top-level targets role.
+	Delegator string `json:"delegator,omitempty"`
+
+	// Paths holds the delegated path patterns of Role that match the path
+	Paths []string `json:"paths,omitempty"`
+
+	// PathHashPrefixes holds the delegated path hash prefixes of Role that
+	// the hash of the path starts with
+	PathHashPrefixes []string `json:"path_hash_prefixes,omitempty"`
+
+	// SuccinctBitLength is set when Role is a succinct hash bin, which the
+	// leading SuccinctBitLength bits of the path hash select
+	SuccinctBitLength int `json:"succinct_bit_length,omitempty"`
+}
+
+// TargetInfo describes a target file as listed by its targets role
+type TargetInfo struct {
+	Length int64             `json:"length"`
+	Hashes map[string]string `json:"hashes"`
+	Custom json.RawMessage   `json:"custom,omitempty"`
+}
+
+// newTargetInfo converts target file metadata into a TargetInfo with
+// hex-encoded hashes
+func newTargetInfo(targetFile *metadata.TargetFiles) *TargetInfo {
+	info := &TargetInfo{
+		Length: targetFile.Length,
+		Hashes: make(map[string]string, len(targetFile.Hashes)),
+	}
+	for algorithm, digest := range targetFile.Hashes {
+		info.Hashes[algorithm] = digest.String()
+	}
+	if targetFile.Custom != nil {
+		info.Custom = *targetFile.Custom
+	}
+
+	return info
+}
+
+// clone returns a deep copy of d, so that callers may modify a decision that
+// is also cached
+func (d Decision) clone() Decision {
+	d.RoleChain = slices.Clone(d.RoleChain)
+	d.ConsultedRoles = slices.Clone(d.ConsultedRoles)
+	d.Consulted = slices.Clone(d.Consulted)
+	for i := range d.Consulted {
+		d.Consulted[i].Paths = slices.Clone(d.Consulted[i].Paths)
+		d.Consulted[i].PathHashPrefixes = slices.Clone(d.Consulted[i].PathHashPrefixes)
+	}
+	d.Versions = maps.Clone(d.Versions)
+	if d.Target != nil {
+		target := *d.Target
+		target.Hashes = maps.Clone(target.Hashes)
+		target.Custom = slices.Clone(target.Custom)
+		d.Target = &target
+	}
+
+	return d
+}
+
+// deny marks the decision as denied for reason
+func (d Decision) deny(reason DenyReason) Decision {
+	d.Allowed = false
+	d.Reason = reason
+	d.RoleChain = nil
+	d.Target = nil
+	return d
+}
+
+// topLevelVersions returns the versions of the top-level metadata
+func (c *Client) topLevelVersions() map[string]int64 {
+	return map[string]int64{
+		metadata.ROOT:      c.rootMeta.Signed.Version,
+		metadata.TIMESTAMP: c.timestampMeta.Signed.Version,
+		metadata.SNAPSHOT:  c.snapshotMeta.Signed.Version,
+		metadata.TARGETS:   c.targetsMeta.Signed.Version,
+	}
+}
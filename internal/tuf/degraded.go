@@ -0,0 +1,161 @@
+package tuf
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FailurePolicy controls how a Refresher authorizes paths after a metadata
+// refresh fails
+type FailurePolicy int
+
+const (
+	// FailOpenUntilExpiry keeps authorizing from the last verified state
+	// until that state expires
+	FailOpenUntilExpiry FailurePolicy = iota
+	// FailClosed denies every path from the first failed refresh until a
+	// refresh succeeds
+	FailClosed
+)
+
+// String returns the configuration name of the policy
+func (p FailurePolicy) String() string {
+	switch p {
+	case FailOpenUntilExpiry:
+		return "fail-open-until-expiry"
+	case FailClosed:
+		return "fail-closed"
+	default:
+		return fmt.Sprintf("FailurePolicy(%d)", int(p))
+	}
+}
+
+// ParseFailurePolicy parses a policy name as returned by FailurePolicy.String
+func ParseFailurePolicy(name string) (FailurePolicy, error) {
+	switch name {
+	case "", "fail-open-until-expiry":
+		return FailOpenUntilExpiry, nil
+	case "fail-closed":
+		return FailClosed, nil
+	default:
+		return FailOpenUntilExpiry, fmt.Errorf("unknown failure policy: %s", name)
+	}
+}
+
+// RefreshStatus describes the outcome of the most recent metadata refresh
+type RefreshStatus struct {
+	// Degraded reports that the last refresh failed and lookups are served
+	// from the last verified state, or denied under FailClosed
+	Degraded bool
+
+	// Authorizing reports whether lookups can still allow paths. It is false
+	// while degraded under FailClosed, and once the last verified state has
+	// expired.
+	Authorizing bool
+
+	// LastError is the error of the last refresh, if it failed
+	LastError error
+
+	// ConsecutiveFailures counts refreshes failed since the last success
+	ConsecutiveFailures int
+
+	// Successes and Failures count all refreshes since the initial load
+	Successes uint64
+	Failures  uint64
+
+	// LastAttempt and LastSuccess are the times of the last refresh attempt
+	// and of the last successful load
+	LastAttempt time.Time
+	LastSuccess time.Time
+
+	// Expires is when the last verified state expires
+	Expires time.Time
+}
+
+// refreshResult records the outcome of a refresh attempt
+type refreshResult struct {
+	err                 error
+	consecutiveFailures int
+	successes           uint64
+	failures            uint64
+	lastAttempt         time.Time
+	lastSuccess         time.Time
+}
+
+// recordRefresh records the outcome of a refresh attempt at now
+func (r *Refresher) recordRefresh(now time.Time, err error) {
+	result := *r.result.Load()
+	result.err = err
+	result.lastAttempt = now
+	if err != nil {
+		result.consecutiveFailures++
+		result.failures++
+	} else {
+		result.consecutiveFailures = 0
+		result.successes++
+		result.lastSuccess = now
+	}
+
+	r.result.Store(&result)
+}
+
+// Status returns the current refresh status
+func (r *Refresher) Status() RefreshStatus {
+	client := r.Client()
+	result := r.result.Load()
+
+	status := RefreshStatus{
+		Degraded:            result.err != nil,
+		LastError:           result.err,
+		ConsecutiveFailures: result.consecutiveFailures,
+		Successes:           result.successes,
+		Failures:            result.failures,
+		LastAttempt:         result.lastAttempt,
+		LastSuccess:         result.lastSuccess,
+		Expires:             client.Expires(),
+	}
+	status.Authorizing = client.checkTopLevelExpiry() == nil &&
+		!(status.Degraded && r.cfg.FailurePolicy == FailClosed)
+
+	return status
+}
+
+// Decide looks up path in the current client, applying the failure policy
+// when the last refresh failed
+func (r *Refresher) Decide(path string) (Decision, error) {
+	return r.DecideContext(context.Background(), path)
+}
+
+// DecideContext is Decide recording the decision in a span that is a child
+// of any span in ctx
+func (r *Refresher) DecideContext(ctx context.Context, path string) (Decision, error) {
+	return r.DecideRequest(ctx, DecisionRequest{Path: path})
+}
+
+// DecideRequest is DecideContext for a request whose method and host key the
+// decision cache when so configured
+func (r *Refresher) DecideRequest(ctx context.Context, req DecisionRequest) (Decision, error) {
+	ctx, span := tracer.Start(ctx, "tuf.Decide", trace.WithAttributes(attribute.String("tuf.path", req.Path)))
+	defer span.End()
+
+	degraded := r.result.Load().err != nil
+
+	decision, cached, err := r.decide(ctx, r.Client(), req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return decision, err
+	}
+
+	if degraded && r.cfg.FailurePolicy == FailClosed {
+		decision = decision.deny(DenyRefreshFailed)
+	}
+	traceDecision(span, decision, cached)
+
+	return decision, nil
+}
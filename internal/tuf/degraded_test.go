@@ -0,0 +1,109 @@
+package tuf
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// testClock is a Config.Clock that tests move forward by hand
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+// failLoads makes every reload of refresher fail with err
+func failLoads(refresher *Refresher, err error) {
+	refresher.load = func(Config, *Client) (*Client, error) {
+		return nil, err
+	}
+}
+
+func TestFailurePolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      FailurePolicy
+		advance     time.Duration
+		authorizing bool
+		reason      DenyReason
+	}{
+		{name: "fail open before expiry", policy: FailOpenUntilExpiry, authorizing: true},
+		{name: "fail open after expiry", policy: FailOpenUntilExpiry, advance: 48 * time.Hour, reason: DenyMetadataExpired},
+		{name: "fail closed before expiry", policy: FailClosed, reason: DenyRefreshFailed},
+		{name: "fail closed after expiry", policy: FailClosed, advance: 48 * time.Hour, reason: DenyRefreshFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &testClock{now: time.Now()}
+			refresher, _, _ := newTestRefresher(t, Config{Clock: clock.Now, FailurePolicy: tt.policy})
+			expires := refresher.Client().Expires()
+
+			failLoads(refresher, errors.New("repository unavailable"))
+			if _, err := refresher.Refresh(); err == nil {
+				t.Fatal("Refresh() error = nil, want the loader error")
+			}
+			clock.now = clock.now.Add(tt.advance)
+
+			status := refresher.Status()
+			if !status.Degraded || status.Authorizing != tt.authorizing {
+				t.Errorf("Status() degraded = %v, authorizing = %v, want true, %v", status.Degraded, status.Authorizing, tt.authorizing)
+			}
+			if !status.Expires.Equal(expires) {
+				t.Errorf("Status() expires = %v, want %v", status.Expires, expires)
+			}
+
+			decision, err := refresher.Decide("/v2/library/alpine")
+			if err != nil {
+				t.Fatalf("Decide() error = %v", err)
+			}
+			if decision.Allowed != tt.authorizing || decision.Reason != tt.reason {
+				t.Errorf("Decide() allowed = %v, reason = %q, want %v, %q", decision.Allowed, decision.Reason, tt.authorizing, tt.reason)
+			}
+		})
+	}
+}
+
+func TestRefreshStatusCountsFailures(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	refresher, _, _ := newTestRefresher(t, Config{Clock: clock.Now})
+	loaded := clock.now
+
+	loadErr := errors.New("repository unavailable")
+	failLoads(refresher, loadErr)
+	for i := 1; i <= 3; i++ {
+		clock.now = clock.now.Add(time.Minute)
+		refresher.Refresh()
+
+		status := refresher.Status()
+		if status.ConsecutiveFailures != i || status.Failures != uint64(i) {
+			t.Errorf("after %d failures: Status() consecutive failures = %d, failures = %d", i, status.ConsecutiveFailures, status.Failures)
+		}
+		if !errors.Is(status.LastError, loadErr) {
+			t.Errorf("after %d failures: Status() last error = %v, want %v", i, status.LastError, loadErr)
+		}
+		if !status.LastAttempt.Equal(clock.now) || !status.LastSuccess.Equal(loaded) {
+			t.Errorf("after %d failures: Status() last attempt = %v, last success = %v, want %v, %v", i, status.LastAttempt, status.LastSuccess, clock.now, loaded)
+		}
+	}
+
+	refresher.load = newClient
+	clock.now = clock.now.Add(time.Minute)
+	if _, err := refresher.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	status := refresher.Status()
+	if status.Degraded || status.LastError != nil || status.ConsecutiveFailures != 0 {
+		t.Errorf("Status() degraded = %v, last error = %v, consecutive failures = %d, want recovered", status.Degraded, status.LastError, status.ConsecutiveFailures)
+	}
+	if status.Successes != 1 || status.Failures != 3 {
+		t.Errorf("Status() successes = %d, failures = %d, want 1, 3", status.Successes, status.Failures)
+	}
+	if !status.LastSuccess.Equal(clock.now) {
+		t.Errorf("Status() last success = %v, want %v", status.LastSuccess, clock.now)
+	}
+}
@@ -0,0 +1,106 @@
+package tuf
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+// ErrMetadataExpired is returned when trusted metadata is past its expiry
+var ErrMetadataExpired = errors.New("metadata has expired")
+
+// ExpiryPolicy controls how the client treats expired metadata
+type ExpiryPolicy int
+
+const (
+	// ExpiryPolicyDeny refuses to load expired metadata and denies every
+	// path once any metadata involved in a decision has expired
+	ExpiryPolicyDeny ExpiryPolicy = iota
+	// ExpiryPolicyAllowExpired keeps authorizing from expired metadata.
+	// This is a degraded mode that gives up freeze attack protection.
+	ExpiryPolicyAllowExpired
+)
+
+// String returns the configuration name of the policy
+func (p ExpiryPolicy) String() string {
+	switch p {
+	case ExpiryPolicyDeny:
+		return "deny"
+	case ExpiryPolicyAllowExpired:
+		return "allow-expired"
+	default:
+		return fmt.Sprintf("ExpiryPolicy(%d)", int(p))
+	}
+}
+
+// ParseExpiryPolicy parses a policy name as returned by ExpiryPolicy.String
+func ParseExpiryPolicy(name string) (ExpiryPolicy, error) {
+	switch name {
+	case "", "deny":
+		return ExpiryPolicyDeny, nil
+	case "allow-expired":
+		return ExpiryPolicyAllowExpired, nil
+	default:
+		return ExpiryPolicyDeny, fmt.Errorf("unknown expiry policy: %s", name)
+	}
+}
+
+// checkExpiry returns ErrMetadataExpired if expires is before now and the
+// policy does not allow expired metadata
+func checkExpiry(policy ExpiryPolicy, roleName string, expires, now time.Time) error {
+	if policy == ExpiryPolicyAllowExpired || !now.After(expires) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s expired at %s", ErrMetadataExpired, roleName, expires.UTC().Format(time.RFC3339))
+}
+
+// checkTopLevelExpiry checks the expiry of root, timestamp, snapshot and
+// targets against the client clock
+func (c *Client) checkTopLevelExpiry() error {
+	now := c.now()
+
+	if err := checkExpiry(c.expiryPolicy, metadata.ROOT, c.rootMeta.Signed.Expires, now); err != nil {
+		return err
+	}
+	if err := checkExpiry(c.expiryPolicy, metadata.TIMESTAMP, c.timestampMeta.Signed.Expires, now); err != nil {
+		return err
+	}
+	if err := checkExpiry(c.expiryPolicy, metadata.SNAPSHOT, c.snapshotMeta.Signed.Expires, now); err != nil {
+		return err
+	}
+
+	return checkExpiry(c.expiryPolicy, metadata.TARGETS, c.targetsMeta.Signed.Expires, now)
+}
+
+// Expires returns the earliest expiry of the top-level metadata, after which
+// no path is authorized unless expired metadata is allowed
+func (c *Client) Expires() time.Time {
+	expires := c.rootMeta.Signed.Expires
+	for _, t := range []time.Time{
+		c.timestampMeta.Signed.Expires,
+		c.snapshotMeta.Signed.Expires,
+		c.targetsMeta.Signed.Expires,
+	} {
+		if t.Before(expires) {
+			expires = t
+		}
+	}
+
+	return expires
+}
+
+// earliestExpiry returns the earliest expiry of all loaded metadata, after
+// which some lookup result may change
+func (c *Client) earliestExpiry() time.Time {
+	expires := c.Expires()
+	for _, meta := range c.delegatedMeta {
+		if meta.Signed.Expires.Before(expires) {
+			expires = meta.Signed.Expires
+		}
+	}
+
+	return expires
+}
@@ -0,0 +1,195 @@
+package tuf
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+// expiryBoundary is when the role under test expires. All other roles expire
+// a year later.
+var expiryBoundary = time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// expiryTestPath is listed by the most deeply delegated role, so deciding it
+// consults every role
+const expiryTestPath = "/v2/library/alpine/manifests/latest"
+
+// writeExpiryRepo writes a repository to dir in which expiringRole expires at
+// expiryBoundary
+func writeExpiryRepo(t *testing.T, dir, expiringRole string) {
+	t.Helper()
+
+	repo := newTestRepo(t, expiryBoundary.AddDate(1, 0, 0))
+	repo.delegate(metadata.TARGETS, metadata.DelegatedRole{
+		Name:  "registry-library",
+		Paths: []string{"/v2/library/*/manifests/*"},
+	})
+	repo.delegate("registry-library", metadata.DelegatedRole{
+		Name:  "library-alpine",
+		Paths: []string{"/v2/library/alpine/manifests/*"},
+	})
+	repo.addTarget("library-alpine", expiryTestPath)
+
+	switch expiringRole {
+	case metadata.ROOT:
+		repo.root.Signed.Expires = expiryBoundary
+	case metadata.TIMESTAMP:
+		repo.timestamp.Signed.Expires = expiryBoundary
+	case metadata.SNAPSHOT:
+		repo.snapshot.Signed.Expires = expiryBoundary
+	default:
+		repo.targets[expiringRole].Signed.Expires = expiryBoundary
+	}
+
+	repo.write(dir)
+}
+
+func TestExpiryBoundaries(t *testing.T) {
+	roles := []string{metadata.ROOT, metadata.TIMESTAMP, metadata.SNAPSHOT, metadata.TARGETS, "registry-library", "library-alpine"}
+	offsets := []struct {
+		name    string
+		offset  time.Duration
+		expired bool
+	}{
+		{"before expiry", -time.Nanosecond, false},
+		{"at expiry", 0, false},
+		{"after expiry", time.Nanosecond, true},
+	}
+	policies := []ExpiryPolicy{ExpiryPolicyDeny, ExpiryPolicyAllowExpired}
+	topLevel := map[string]bool{metadata.ROOT: true, metadata.TIMESTAMP: true, metadata.SNAPSHOT: true, metadata.TARGETS: true}
+
+	for _, roleName := range roles {
+		dir := t.TempDir()
+		writeExpiryRepo(t, dir, roleName)
+
+		for _, offset := range offsets {
+			for _, policy := range policies {
+				t.Run(roleName+"/"+offset.name+"/"+policy.String(), func(t *testing.T) {
+					denied := offset.expired && policy == ExpiryPolicyDeny
+
+					// Loading at the boundary. Expired top-level metadata
+					// fails the load; an expired delegated role only denies
+					// the paths it is trusted for.
+					now := expiryBoundary.Add(offset.offset)
+					cfg := Config{
+						RepoPath:     dir,
+						ExpiryPolicy: policy,
+						Clock:        func() time.Time { return now },
+					}
+					loaded, err := NewClient(cfg)
+					switch {
+					case denied && topLevel[roleName]:
+						if !errors.Is(err, ErrMetadataExpired) {
+							t.Fatalf("NewClient() error = %v, want %v", err, ErrMetadataExpired)
+						}
+					case err != nil:
+						t.Fatalf("NewClient() error = %v", err)
+					case denied:
+						loadErrors := loaded.LoadErrors()
+						if len(loadErrors) != 1 || loadErrors[0].Role != roleName || loadErrors[0].State != RoleExpired || !errors.Is(loadErrors[0], ErrMetadataExpired) {
+							t.Fatalf("LoadErrors() = %v, want %s expired", loadErrors, roleName)
+						}
+						decision, err := loaded.Decide(expiryTestPath)
+						if err != nil {
+							t.Fatalf("Decide() error = %v", err)
+						}
+						if decision.Allowed || decision.Reason != DenyMetadataExpired {
+							t.Errorf("Decide() with %s expired at load allowed = %v, reason = %q, want denied with %q", roleName, decision.Allowed, decision.Reason, DenyMetadataExpired)
+						}
+					default:
+						if loadErrors := loaded.LoadErrors(); len(loadErrors) != 0 {
+							t.Fatalf("LoadErrors() = %v, want none", loadErrors)
+						}
+					}
+
+					// Deciding at the boundary with metadata loaded earlier
+					now = expiryBoundary.Add(-time.Hour)
+					client, err := NewClient(cfg)
+					if err != nil {
+						t.Fatalf("NewClient() error = %v", err)
+					}
+					now = expiryBoundary.Add(offset.offset)
+
+					decision, err := client.Decide(expiryTestPath)
+					if err != nil {
+						t.Fatalf("Decide() error = %v", err)
+					}
+					if decision.Allowed == denied {
+						t.Errorf("Decide() allowed = %v, want %v", decision.Allowed, !denied)
+					}
+					if denied && decision.Reason != DenyMetadataExpired {
+						t.Errorf("Decide() reason = %q, want %q", decision.Reason, DenyMetadataExpired)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestUnusableDelegatedRoleOnlyDeniesItsPaths(t *testing.T) {
+	tests := []struct {
+		name      string
+		breakRole func(repo *testRepo, dir string)
+		state     RoleState
+		reason    DenyReason
+	}{
+		{
+			name: "expired",
+			breakRole: func(repo *testRepo, dir string) {
+				repo.targets["stale"].Signed.Expires = time.Now().Add(-time.Hour)
+				repo.write(dir)
+			},
+			state:  RoleExpired,
+			reason: DenyMetadataExpired,
+		},
+		{
+			name: "version differs from snapshot",
+			breakRole: func(repo *testRepo, dir string) {
+				repo.write(dir)
+				repo.targets["stale"].Signed.Version++
+				repo.sign("stale", repo.targets["stale"])
+				repo.writeFile(dir, "stale", repo.targets["stale"].ToFile)
+			},
+			state:  RoleInvalid,
+			reason: DenyRoleUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepo(t, time.Now().Add(24*time.Hour))
+			repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "stale", Paths: []string{"/stale/*"}})
+			repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "current", Paths: []string{"/current/*"}})
+			repo.addTarget("stale", "/stale/image")
+			repo.addTarget("current", "/current/image")
+
+			dir := t.TempDir()
+			tt.breakRole(repo, dir)
+
+			if _, err := NewClient(Config{RepoPath: dir, StrictDelegations: true}); !errors.Is(err, ErrRoleUnavailable) {
+				t.Fatalf("NewClient() error = %v, want %v in strict mode", err, ErrRoleUnavailable)
+			}
+
+			client, err := NewClient(Config{RepoPath: dir})
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			if status := client.GetRoleStatus()["stale"]; status.State != tt.state || status.Error == "" {
+				t.Errorf("stale status = %+v, want %s with an error", status, tt.state)
+			}
+
+			decision, err := client.Decide("/stale/image")
+			if err != nil {
+				t.Fatalf("Decide() error = %v", err)
+			}
+			if decision.Allowed || decision.Reason != tt.reason {
+				t.Errorf("Decide(/stale/image) allowed = %v, reason = %q, want denied with %q", decision.Allowed, decision.Reason, tt.reason)
+			}
+			if allowed, err := client.VerifyPath("/current/image"); err != nil || !allowed {
+				t.Errorf("VerifyPath(/current/image) = %v, %v, want allowed", allowed, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package tuf
+
+import (
+	"context"
+	gopath "path"
+	"sort"
+	"strings"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+// targetIndex is an immutable radix tree over every target path the loaded
+// metadata allows, mapping each path to its precomputed lookup result. A
+// lookup walks at most one node per byte of the path, independent of the
+// number of targets and roles.
+type targetIndex struct {
+	root radixNode
+	size int
+}
+
+// radixNode is a radix tree node. Its children are sorted by the first byte
+// of their prefix, which is unique among siblings.
+type radixNode struct {
+	prefix   string
+	children []*radixNode
+	result   *lookupResult
+}
+
+// lookup returns the precomputed result for path, if it is an allowed target
+func (t *targetIndex) lookup(path string) (lookupResult, bool) {
+	if t == nil {
+		return lookupResult{}, false
+	}
+
+	node := &t.root
+	for path != "" {
+		_, child := node.child(path[0])
+		if child == nil || !strings.HasPrefix(path, child.prefix) {
+			return lookupResult{}, false
+		}
+		path = path[len(child.prefix):]
+		node = child
+	}
+
+	if node.result == nil {
+		return lookupResult{}, false
+	}
+
+	// The indexed result is shared by every lookup of the path
+	return node.result.clone(), true
+}
+
+// paths returns every path in the tree, sorted
+func (t *targetIndex) paths() []string {
+	if t == nil {
+		return nil
+	}
+
+	paths := make([]string, 0, t.size)
+	var walk func(node *radixNode, prefix string)
+	walk = func(node *radixNode, prefix string) {
+		prefix += node.prefix
+		if node.result != nil {
+			paths = append(paths, prefix)
+		}
+		for _, child := range node.children {
+			walk(child, prefix)
+		}
+	}
+	walk(&t.root, "")
+
+	return paths
+}
+
+// insert adds path to the tree, splitting nodes on partial prefix matches
+func (t *targetIndex) insert(path string, result *lookupResult) {
+	node := &t.root
+	for path != "" {
+		i, child := node.child(path[0])
+		if child == nil {
+			node.addChild(&radixNode{prefix: path, result: result})
+			t.size++
+			return
+		}
+
+		common := commonPrefixLen(path, child.prefix)
+		if common < len(child.prefix) {
+			// Split the child so that its prefix ends where path diverges
+			split := &radixNode{prefix: child.prefix[:common], children: []*radixNode{child}}
+			child.prefix = child.prefix[common:]
+			node.children[i] = split
+			child = split
+		}
+
+		path = path[common:]
+		node = child
+	}
+
+	if node.result == nil {
+		t.size++
+	}
+	node.result = result
+}
+
+// child returns the child whose prefix starts with b and its position
+func (n *radixNode) child(b byte) (int, *radixNode) {
+	i := sort.Search(len(n.children), func(i int) bool {
+		return n.children[i].prefix[0] >= b
+	})
+	if i < len(n.children) && n.children[i].prefix[0] == b {
+		return i, n.children[i]
+	}
+
+	return i, nil
+}
+
+// addChild inserts child in order
+func (n *radixNode) addChild(child *radixNode) {
+	i, _ := n.child(child.prefix[0])
+	n.children = append(n.children, nil)
+	copy(n.children[i+1:], n.children[i:])
+	n.children[i] = child
+}
+
+// commonPrefixLen returns the length of the common prefix of a and b
+func commonPrefixLen(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+
+	return n
+}
+
+// patternIndex is a trie over the path segments of one delegator's path
+// patterns, returning the roles whose patterns can match a path without
+// testing every pattern. Literal segments are followed by map lookup; only
+// segments containing wildcards are matched one by one.
+type patternIndex struct {
+	root patternNode
+	// hashPrefixRoles are delegated by path hash prefix rather than by
+	// pattern and are checked for every path
+	hashPrefixRoles []int
+}
+
+// patternNode is a node of a patternIndex, holding the indices of the roles
+// whose patterns end at it
+type patternNode struct {
+	literal  map[string]*patternNode
+	wildcard []patternEdge
+	roles    []int
+}
+
+// patternEdge is a wildcard pattern segment leading to a node
+type patternEdge struct {
+	segment string
+	node    *patternNode
+}
+
+// newPatternIndex indexes the path patterns of roles
+func newPatternIndex(roles []metadata.DelegatedRole) *patternIndex {
+	index := &patternIndex{}
+
+	for i, role := range roles {
+		if len(role.Paths) == 0 {
+			index.hashPrefixRoles = append(index.hashPrefixRoles, i)
+			continue
+		}
+
+		for _, pattern := range role.Paths {
+			node := &index.root
+			for _, segment := range strings.Split(pattern, "/") {
+				node = node.next(segment)
+			}
+			node.roles = append(node.roles, i)
+		}
+	}
+
+	return index
+}
+
+// next returns the child node for a pattern segment, creating it if needed
+func (n *patternNode) next(segment string) *patternNode {
+	if !strings.ContainsAny(segment, `*?[\`) {
+		if n.literal == nil {
+			n.literal = make(map[string]*patternNode)
+		}
+		if _, ok := n.literal[segment]; !ok {
+			n.literal[segment] = &patternNode{}
+		}
+		return n.literal[segment]
+	}
+
+	for _, edge := range n.wildcard {
+		if edge.segment == segment {
+			return edge.node
+		}
+	}
+	edge := patternEdge{segment: segment, node: &patternNode{}}
+	n.wildcard = append(n.wildcard, edge)
+
+	return edge.node
+}
+
+// candidates returns the indices, in delegation order, of the roles that may
+// be trusted for path. Roles found through their patterns match path; hash
+// prefix roles still need to be checked.
+func (p *patternIndex) candidates(path string) []int {
+	matched := make(map[int]bool)
+
+	var walk func(node *patternNode, segments []string)
+	walk = func(node *patternNode, segments []string) {
+		if len(segments) == 0 {
+			for _, i := range node.roles {
+				matched[i] = true
+			}
+			return
+		}
+
+		if next, ok := node.literal[segments[0]]; ok {
+			walk(next, segments[1:])
+		}
+		for _, edge := range node.wildcard {
+			// Malformed patterns never match
+			if ok, err := gopath.Match(edge.segment, segments[0]); err == nil && ok {
+				walk(edge.node, segments[1:])
+			}
+		}
+	}
+	walk(&p.root, strings.Split(path, "/"))
+
+	for _, i := range p.hashPrefixRoles {
+		matched[i] = true
+	}
+
+	candidates := make([]int, 0, len(matched))
+	for i := range matched {
+		candidates = append(candidates, i)
+	}
+	sort.Ints(candidates)
+
+	return candidates
+}
+
+// buildIndex indexes the delegation patterns of every loaded role and then
+// every allowed target path, precomputing its lookup result
+func (c *Client) buildIndex() {
+	c.patterns = make(map[string]*patternIndex)
+	c.forEachTargetsRole(func(roleName string, meta *metadata.Metadata[metadata.TargetsType]) {
+		if meta.Signed.Delegations != nil && len(meta.Signed.Delegations.Roles) > 0 {
+			c.patterns[roleName] = newPatternIndex(meta.Signed.Delegations.Roles)
+		}
+	})
+
+	index := &targetIndex{}
+	c.forEachTargetsRole(func(roleName string, meta *metadata.Metadata[metadata.TargetsType]) {
+		for path := range meta.Signed.Targets {
+			if _, indexed := index.lookup(path); indexed {
+				continue
+			}
+
+			// A role may list paths it is not trusted for, which the
+			// search rejects
+			if result := c.search(context.Background(), path); result.target != nil {
+				index.insert(path, &result)
+			}
+		}
+	})
+	c.index = index
+}
+
+// forEachTargetsRole calls fn for the top-level targets role and every loaded
+// delegated role
+func (c *Client) forEachTargetsRole(fn func(roleName string, meta *metadata.Metadata[metadata.TargetsType])) {
+	fn(metadata.TARGETS, c.targetsMeta)
+	for roleName, meta := range c.delegatedMeta {
+		fn(roleName, meta)
+	}
+}
@@ -0,0 +1,192 @@
+package tuf
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+// benchmarkTargetsPerBin is the approximate number of targets per hash bin in
+// benchmark repositories
+const benchmarkTargetsPerBin = 4096
+
+// benchmarkTargetPath returns the path of the i-th synthetic target
+func benchmarkTargetPath(i int) string {
+	return fmt.Sprintf("/v2/library/image-%d/manifests/latest", i)
+}
+
+// writeBenchmarkRepo writes a repository with numTargets synthetic targets to
+// dir. targets delegates /v2/library/*/manifests/* to registry-library, which
+// spreads the targets over succinct hash bins.
+func writeBenchmarkRepo(b *testing.B, dir string, numTargets int) {
+	b.Helper()
+
+	repo := newTestRepo(b, time.Now().Add(24*time.Hour))
+	repo.delegate(metadata.TARGETS, metadata.DelegatedRole{
+		Name:        "registry-library",
+		Paths:       []string{"/v2/library/*/manifests/*"},
+		Terminating: true,
+	})
+
+	bitLength := 1
+	for (1<<bitLength)*benchmarkTargetsPerBin < numTargets && bitLength < 16 {
+		bitLength++
+	}
+	succinct := repo.delegateSuccinct("registry-library", "registry-library-hb", bitLength)
+
+	for i := 0; i < numTargets; i++ {
+		path := benchmarkTargetPath(i)
+		for _, bin := range succinct.GetRolesForTarget(path) {
+			repo.addTarget(bin.Name, path)
+		}
+	}
+
+	repo.write(dir)
+}
+
+// BenchmarkDecide measures allowed, denied and undelegated lookups against
+// repositories of increasing size. Lookups are bounded by the path length, so
+// the time per lookup should not grow with the number of targets.
+//
+//	go test -run '^$' -bench BenchmarkDecide ./internal/tuf
+func BenchmarkDecide(b *testing.B) {
+	sizes := []struct {
+		name       string
+		numTargets int
+	}{
+		{"10k", 10_000},
+		{"100k", 100_000},
+		{"1M", 1_000_000},
+	}
+
+	for _, size := range sizes {
+		b.Run(size.name, func(b *testing.B) {
+			dir := b.TempDir()
+			writeBenchmarkRepo(b, dir, size.numTargets)
+
+			start := time.Now()
+			client, err := NewClient(Config{RepoPath: dir})
+			if err != nil {
+				b.Fatalf("NewClient() error = %v", err)
+			}
+			b.Logf("loaded %d targets in %s", size.numTargets, time.Since(start).Round(time.Millisecond))
+
+			lookups := []struct {
+				name    string
+				pathFor func(i int) string
+			}{
+				{"allowed", func(i int) string { return benchmarkTargetPath(i % size.numTargets) }},
+				{"denied", func(i int) string { return benchmarkTargetPath(size.numTargets + i) }},
+				{"undelegated", func(i int) string { return fmt.Sprintf("/v2/other/image-%d/manifests/latest", i) }},
+			}
+			for _, lookup := range lookups {
+				paths := make([]string, 1024)
+				for i := range paths {
+					paths[i] = lookup.pathFor(i * 7919)
+				}
+
+				b.Run(lookup.name, func(b *testing.B) {
+					b.ReportAllocs()
+					for i := 0; i < b.N; i++ {
+						if _, err := client.Decide(paths[i%len(paths)]); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestIndexedDecisionsDoNotShareSlices(t *testing.T) {
+	repo := newTestRepo(t, time.Now().Add(24*time.Hour))
+	repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "library", Paths: []string{"/v2/library/*"}})
+	repo.addTarget("library", "/v2/library/alpine")
+
+	dir := t.TempDir()
+	repo.write(dir)
+
+	client, err := NewClient(Config{RepoPath: dir})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	first, err := client.Decide("/v2/library/alpine")
+	if err != nil {
+		t.Fatalf("Decide() error = %v", err)
+	}
+	first.RoleChain[1] = "changed"
+	first.ConsultedRoles[1] = "changed"
+	first.Consulted[1].Role = "changed"
+	first.Consulted[1].Paths[0] = "changed"
+
+	second, err := client.Decide("/v2/library/alpine")
+	if err != nil {
+		t.Fatalf("Decide() error = %v", err)
+	}
+	if want := []string{metadata.TARGETS, "library"}; !slices.Equal(second.RoleChain, want) || !slices.Equal(second.ConsultedRoles, want) {
+		t.Errorf("Decide() role chain = %v, consulted roles = %v, want %v", second.RoleChain, second.ConsultedRoles, want)
+	}
+	if consulted := second.Consulted[1]; consulted.Role != "library" || !slices.Equal(consulted.Paths, []string{"/v2/library/*"}) {
+		t.Errorf("Decide() consulted = %+v, want library matched by /v2/library/*", consulted)
+	}
+}
+
+func TestAllowedPathsOnlyListDecidableTargets(t *testing.T) {
+	repo := newTestRepo(t, time.Now().Add(24*time.Hour))
+	repo.addTarget(metadata.TARGETS, "/top")
+	repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "first", Paths: []string{"/shared/*"}, Terminating: true})
+	repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "second", Paths: []string{"/shared/*"}})
+	repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "outside", Paths: []string{"/outside/*"}})
+	repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "hashed", Paths: []string{"/bins/*"}})
+	succinct := repo.delegateSuccinct("hashed", "bin", 1)
+	repo.addTarget("first", "/shared/first")
+	repo.addTarget("second", "/shared/second")
+	repo.addTarget("outside", "/outside/image")
+	repo.addTarget("outside", "/elsewhere/image")
+
+	// One bin lists a path of its own and a path that hashes into the other
+	binPaths := make(map[string]string)
+	for i := 0; len(binPaths) < 2; i++ {
+		path := fmt.Sprintf("/bins/%d", i)
+		bin := succinct.GetRolesForTarget(path)[0].Name
+		if _, ok := binPaths[bin]; !ok {
+			binPaths[bin] = path
+		}
+	}
+	bins := succinct.GetRoles()
+	listed, misplaced := binPaths[bins[0]], binPaths[bins[1]]
+	repo.addTarget(bins[0], listed)
+	repo.addTarget(bins[0], misplaced)
+
+	dir := t.TempDir()
+	repo.write(dir)
+
+	client, err := NewClient(Config{RepoPath: dir})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	paths, err := client.GetAllowedPaths()
+	if err != nil {
+		t.Fatalf("GetAllowedPaths() error = %v", err)
+	}
+	want := []string{listed, "/outside/image", "/shared/first", "/top"}
+	slices.Sort(want)
+	if !slices.Equal(paths, want) {
+		t.Errorf("GetAllowedPaths() = %v, want %v", paths, want)
+	}
+
+	for _, path := range []string{"/top", "/shared/first", "/shared/second", "/outside/image", "/elsewhere/image", listed, misplaced} {
+		decision, err := client.Decide(path)
+		if err != nil {
+			t.Fatalf("Decide(%q) error = %v", path, err)
+		}
+		if listed := slices.Contains(paths, path); decision.Allowed != listed {
+			t.Errorf("Decide(%q) allowed = %v, but listed by GetAllowedPaths = %v", path, decision.Allowed, listed)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package tuf
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Refresher keeps a verified Client current by periodically reloading the
+// repository. Each reload builds and verifies a complete new Client and swaps
+// it in atomically, so lookups in flight keep using the state they started
+// with and never observe a partially loaded one. If a reload fails, the
+// previously verified Client stays in place and the Refresher reports itself
+// degraded until a reload succeeds.
+type Refresher struct {
+	cfg    Config
+	client atomic.Pointer[Client]
+	result atomic.Pointer[refreshResult]
+	cache  *decisionCache
+
+	// load builds and verifies a client for a configuration, carrying over
+	// the trusted state of the previous client. It is newClient except in
+	// tests.
+	load func(cfg Config, previous *Client) (*Client, error)
+
+	// mu serializes reloads
+	mu sync.Mutex
+}
+
+// NewRefresher loads and verifies the initial Client for cfg
+func NewRefresher(cfg Config) (*Refresher, error) {
+	client, err := traceLoad(context.Background(), "tuf.Load", func() (*Client, error) {
+		return NewClient(cfg)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Refresher{cfg: cfg, load: newClient}
+	if cfg.DecisionCacheSize > 0 {
+		r.cache = newDecisionCache(cfg.DecisionCacheSize, cfg.DecisionCacheTTL, cfg.DecisionCacheKeyMethod, cfg.DecisionCacheKeyHost)
+	}
+	r.client.Store(client)
+	r.result.Store(&refreshResult{lastAttempt: client.now(), lastSuccess: client.now()})
+
+	return r, nil
+}
+
+// Client returns the current verified client. Callers should fetch it once
+// per request and use that client throughout.
+func (r *Refresher) Client() *Client {
+	return r.client.Load()
+}
+
+// Refresh reloads and verifies the repository and swaps in the result. On
+// failure the current client is kept and the error is returned. Refresh
+// returns the client in effect afterwards.
+func (r *Refresher) Refresh() (*Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current := r.client.Load()
+
+	client, err := traceLoad(context.Background(), "tuf.Refresh", func() (*Client, error) {
+		return r.reload(current)
+	})
+	r.recordRefresh(current.now(), err)
+	if err != nil {
+		return current, err
+	}
+
+	r.client.Store(client)
+
+	return client, nil
+}
+
+// reload loads and verifies a new client to replace current
+func (r *Refresher) reload(current *Client) (*Client, error) {
+	cfg, err := r.anchoredConfig(current)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := r.load(cfg, current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh metadata: %w", err)
+	}
+
+	return client, nil
+}
+
+// anchoredConfig returns the configuration for reloading after current. If
+// current trusts a newer root than the configured anchor, that root becomes
+// the anchor, so a reload can never fall back to an older root.
+func (r *Refresher) anchoredConfig(current *Client) (Config, error) {
+	cfg := r.cfg
+
+	if current.rootMeta.Signed.Version <= rootVersion(cfg.RootBytes) {
+		return cfg, nil
+	}
+
+	rootBytes, err := current.rootMeta.ToBytes(false)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to encode trusted root metadata: %w", err)
+	}
+	cfg.RootBytes = rootBytes
+
+	return cfg, nil
+}
+
+// Run refreshes the client every RefreshInterval, randomized by
+// RefreshJitter, until ctx is done. onRefresh, if set, is called after each
+// attempt with the client in effect and the refresh error, if any. Run
+// returns immediately when RefreshInterval is zero.
+func (r *Refresher) Run(ctx context.Context, onRefresh func(*Client, error)) {
+	if r.cfg.RefreshInterval <= 0 {
+		return
+	}
+
+	for {
+		timer := time.NewTimer(r.nextInterval())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		client, err := r.Refresh()
+		if onRefresh != nil {
+			onRefresh(client, err)
+		}
+	}
+}
+
+// nextInterval returns RefreshInterval shifted by a random amount of up to
+// RefreshJitter of it in either direction
+func (r *Refresher) nextInterval() time.Duration {
+	interval := r.cfg.RefreshInterval
+
+	spread := int64(float64(interval) * r.cfg.RefreshJitter)
+	if spread <= 0 {
+		return interval
+	}
+
+	return interval - time.Duration(spread) + time.Duration(rand.Int63n(2*spread+1))
+}
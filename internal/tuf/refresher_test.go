@@ -0,0 +1,149 @@
+package tuf
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+// newTestRefresher writes a repository delegating /v2/library/* to library,
+// which lists /v2/library/alpine, and returns a Refresher loading it
+func newTestRefresher(t *testing.T, cfg Config) (*Refresher, *testRepo, string) {
+	t.Helper()
+
+	repo := newTestRepo(t, time.Now().Add(24*time.Hour))
+	repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "library", Paths: []string{"/v2/library/*"}})
+	repo.addTarget("library", "/v2/library/alpine")
+
+	dir := t.TempDir()
+	repo.write(dir)
+
+	cfg.RepoPath = dir
+	refresher, err := NewRefresher(cfg)
+	if err != nil {
+		t.Fatalf("NewRefresher() error = %v", err)
+	}
+
+	return refresher, repo, dir
+}
+
+// assertAllowed checks whether refresher allows path
+func assertAllowed(t *testing.T, refresher *Refresher, path string, want bool) {
+	t.Helper()
+
+	decision, err := refresher.Decide(path)
+	if err != nil {
+		t.Fatalf("Decide(%q) error = %v", path, err)
+	}
+	if decision.Allowed != want {
+		t.Errorf("Decide(%q) allowed = %v (reason %q), want %v", path, decision.Allowed, decision.Reason, want)
+	}
+}
+
+func TestRefreshKeepsClientOnFailure(t *testing.T) {
+	refresher, _, _ := newTestRefresher(t, Config{})
+	initial := refresher.Client()
+
+	loadErr := errors.New("repository unavailable")
+	var previous *Client
+	refresher.load = func(_ Config, prev *Client) (*Client, error) {
+		previous = prev
+		return nil, loadErr
+	}
+
+	client, err := refresher.Refresh()
+	if !errors.Is(err, loadErr) {
+		t.Fatalf("Refresh() error = %v, want %v", err, loadErr)
+	}
+	if client != initial {
+		t.Error("Refresh() returned a different client after failing")
+	}
+	if refresher.Client() != initial {
+		t.Error("Client() changed after a failed refresh")
+	}
+	if previous != initial {
+		t.Error("loader was not given the current client")
+	}
+	assertAllowed(t, refresher, "/v2/library/alpine", true)
+}
+
+func TestRefreshSwapsClient(t *testing.T) {
+	refresher, repo, dir := newTestRefresher(t, Config{})
+	initial := refresher.Client()
+
+	repo.addTarget("library", "/v2/library/nginx")
+	repo.targets["library"].Signed.Version++
+	repo.write(dir)
+	next, err := NewClient(Config{RepoPath: dir})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	refresher.load = func(Config, *Client) (*Client, error) {
+		return nil, errors.New("repository unavailable")
+	}
+	if _, err := refresher.Refresh(); err == nil {
+		t.Fatal("Refresh() error = nil, want the loader error")
+	}
+
+	refresher.load = func(Config, *Client) (*Client, error) {
+		return next, nil
+	}
+	client, err := refresher.Refresh()
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if client != next {
+		t.Error("Refresh() did not return the loaded client")
+	}
+	if refresher.Client() != next {
+		t.Error("Client() did not switch to the loaded client")
+	}
+	assertAllowed(t, refresher, "/v2/library/nginx", true)
+
+	// Lookups that fetched the client before the swap keep their state
+	decision, err := initial.Decide("/v2/library/nginx")
+	if err != nil {
+		t.Fatalf("Decide() error = %v", err)
+	}
+	if decision.Allowed {
+		t.Error("previous client allowed a target added after it was loaded")
+	}
+}
+
+func TestRefreshAnchorsToTrustedRoot(t *testing.T) {
+	repo := newTestRepo(t, time.Now().Add(24*time.Hour))
+	dir := t.TempDir()
+	repo.write(dir)
+	rootBytes, err := os.ReadFile(filepath.Join(dir, "root.json"))
+	if err != nil {
+		t.Fatalf("failed to read root: %v", err)
+	}
+
+	refresher, err := NewRefresher(Config{RepoPath: dir, RootBytes: rootBytes})
+	if err != nil {
+		t.Fatalf("NewRefresher() error = %v", err)
+	}
+
+	rotateTargetsKey(t, repo, dir)
+	repo.write(dir)
+	if _, err := refresher.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	var anchor []byte
+	refresher.load = func(cfg Config, previous *Client) (*Client, error) {
+		anchor = cfg.RootBytes
+		return newClient(cfg, previous)
+	}
+	if _, err := refresher.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if version := rootVersion(anchor); version != 2 {
+		t.Errorf("reload anchored to root version %d, want 2", version)
+	}
+}
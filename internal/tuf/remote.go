@@ -0,0 +1,95 @@
+package tuf
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+	"github.com/theupdateframework/go-tuf/v2/metadata/config"
+	"github.com/theupdateframework/go-tuf/v2/metadata/updater"
+)
+
+// remoteDownloadTimeout bounds each metadata download from a remote repository
+const remoteDownloadTimeout = 15 * time.Second
+
+// loadRemoteTopLevel runs the go-tuf updater workflow against the remote
+// repository at cfg.MetadataURL. The updater handles root rotation, rollback
+// protection and the timestamp -> snapshot -> targets chain, caching trusted
+// metadata in cfg.CacheDir. The returned reader downloads delegated role
+// metadata from the same repository.
+//
+// Delegated roles are not searched with the updater's GetTargetInfo. It
+// fetches roles lazily on the first lookup that reaches them, which would put
+// network round trips on the request path, and returns only the target file,
+// without the role chain, consulted roles or deny reason of a Decision.
+// Instead every delegated role is downloaded up front and verified by
+// loadDelegations, as in local mode, so that lookups are answered from the
+// complete delegation graph.
+func (c *Client) loadRemoteTopLevel(cfg Config) (metadataReader, error) {
+	if len(cfg.RootBytes) == 0 {
+		return nil, fmt.Errorf("remote repository requires an initial trusted root")
+	}
+
+	updaterCfg, err := config.New(cfg.MetadataURL, cfg.RootBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure updater: %w", err)
+	}
+
+	if cfg.TargetsURL != "" {
+		updaterCfg.RemoteTargetsURL = cfg.TargetsURL
+	}
+
+	if cfg.CacheDir != "" {
+		updaterCfg.LocalMetadataDir = cfg.CacheDir
+		updaterCfg.LocalTargetsDir = filepath.Join(cfg.CacheDir, "targets")
+	} else {
+		updaterCfg.DisableLocalCache = true
+	}
+
+	up, err := updater.New(updaterCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create updater: %w", err)
+	}
+
+	// The updater has no degraded expiry mode. go-tuf intends
+	// UnsafeSetRefTime for tests, but pinning the reference time to the zero
+	// time is the only way to make it accept expired metadata, which
+	// ExpiryPolicyAllowExpired asks for.
+	if c.expiryPolicy == ExpiryPolicyAllowExpired {
+		up.UnsafeSetRefTime(time.Time{})
+	} else {
+		up.UnsafeSetRefTime(c.now())
+	}
+
+	if err := up.Refresh(); err != nil {
+		return nil, fmt.Errorf("failed to refresh remote metadata: %w", err)
+	}
+
+	trusted := up.GetTrustedMetadataSet()
+	c.rootMeta = trusted.Root
+	c.timestampMeta = trusted.Timestamp
+	c.snapshotMeta = trusted.Snapshot
+	c.targetsMeta = trusted.Targets[metadata.TARGETS]
+
+	return c.remoteReader(updaterCfg), nil
+}
+
+// remoteReader downloads metadata files from the remote repository, bounding
+// each download by the length recorded in the trusted snapshot
+func (c *Client) remoteReader(updaterCfg *config.UpdaterConfig) metadataReader {
+	return func(roleName string, version int64) ([]byte, error) {
+		maxLength := updaterCfg.TargetsMaxLength
+		if info, ok := c.snapshotMeta.Signed.Meta[roleName+".json"]; ok && info.Length > 0 {
+			maxLength = info.Length
+		}
+
+		fileURL, err := url.JoinPath(updaterCfg.RemoteMetadataURL, url.PathEscape(c.metadataFileName(roleName, version)))
+		if err != nil {
+			return nil, err
+		}
+
+		return updaterCfg.Fetcher.DownloadFile(fileURL, maxLength, remoteDownloadTimeout)
+	}
+}
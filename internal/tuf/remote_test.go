@@ -0,0 +1,87 @@
+package tuf
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+
+	"github.com/matglas/tuf-client-verify/internal/tuftest"
+)
+
+func TestRemoteRepository(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		roleChain []string
+	}{
+		{
+			name:      "consistent snapshots",
+			roleChain: []string{metadata.TARGETS, "registry-library"},
+		},
+		{
+			name:      "unversioned files",
+			args:      []string{"-consistent-snapshot=false"},
+			roleChain: []string{metadata.TARGETS, "registry-library"},
+		},
+		{
+			name:      "succinct hash bins",
+			args:      []string{"-succinct-bit-length", "2"},
+			roleChain: []string{metadata.TARGETS, "registry-library", "registry-library-hb-3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoDir, rootBytes := tuftest.GenerateRepository(t, tt.args...)
+
+			client, err := NewClient(Config{
+				MetadataURL: tuftest.ServeRepository(t, repoDir),
+				RootBytes:   rootBytes,
+				CacheDir:    t.TempDir(),
+			})
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+
+			decision, err := client.Decide("/v2/library/alpine/manifests/latest")
+			if err != nil {
+				t.Fatalf("Decide() error = %v", err)
+			}
+			if !decision.Allowed {
+				t.Fatalf("Decide() denied with reason %q, want allowed", decision.Reason)
+			}
+			if !slices.Equal(decision.RoleChain, tt.roleChain) {
+				t.Errorf("Decide() role chain = %v, want %v", decision.RoleChain, tt.roleChain)
+			}
+
+			decision, err = client.Decide("/v2/library/busybox/manifests/latest")
+			if err != nil {
+				t.Fatalf("Decide() error = %v", err)
+			}
+			if decision.Allowed || decision.Reason != DenyTargetNotFound {
+				t.Errorf("Decide() allowed = %v, reason = %q, want denied with %q", decision.Allowed, decision.Reason, DenyTargetNotFound)
+			}
+		})
+	}
+}
+
+func TestRemoteRepositoryRejectsTamperedMetadata(t *testing.T) {
+	repoDir, rootBytes := tuftest.GenerateRepository(t)
+
+	// Changing signed content invalidates the timestamp signature
+	timestampPath := filepath.Join(repoDir, "timestamp.json")
+	timestamp, err := metadata.Timestamp().FromFile(timestampPath)
+	if err != nil {
+		t.Fatalf("failed to read timestamp: %v", err)
+	}
+	timestamp.Signed.Version++
+	if err := timestamp.ToFile(timestampPath, true); err != nil {
+		t.Fatalf("failed to write timestamp: %v", err)
+	}
+
+	if _, err := NewClient(Config{MetadataURL: tuftest.ServeRepository(t, repoDir), RootBytes: rootBytes}); err == nil {
+		t.Fatal("NewClient() succeeded with a tampered timestamp")
+	}
+}
@@ -0,0 +1,203 @@
+package tuf
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+// ErrRoleUnavailable is wrapped by RoleLoadError for delegated role metadata
+// that is missing, cannot be parsed, is not signed by the keys its delegator
+// assigns to it, does not match the snapshot or has expired
+var ErrRoleUnavailable = errors.New("delegated role metadata unavailable")
+
+// RoleState describes whether a delegated role's metadata could be loaded
+type RoleState string
+
+const (
+	// RoleLoaded means the role's metadata was loaded and verified
+	RoleLoaded RoleState = "loaded"
+	// RoleMissing means the role's metadata file could not be read
+	RoleMissing RoleState = "missing"
+	// RoleInvalid means the role's metadata file could not be parsed, is
+	// not signed by a threshold of the keys a delegator assigns to the role,
+	// or its version or hashes differ from those the snapshot lists
+	RoleInvalid RoleState = "invalid"
+	// RoleExpired means the role's metadata had expired when it was loaded
+	RoleExpired RoleState = "expired"
+)
+
+// RoleLoadError reports a delegated role whose metadata is unavailable.
+// Lookups that reach the role are denied rather than passed on to
+// lower-priority roles.
+type RoleLoadError struct {
+	Role string
+	// Delegator is set when the role's metadata is only untrusted through
+	// this delegator's delegation, as its signatures do not meet the
+	// threshold of the keys the delegator assigns to the role
+	Delegator string
+	State     RoleState
+	Err       error
+}
+
+// Error implements the error interface
+func (e *RoleLoadError) Error() string {
+	if e.Delegator != "" {
+		return fmt.Sprintf("%s: %s delegated by %s is %s: %v", ErrRoleUnavailable, e.Role, e.Delegator, e.State, e.Err)
+	}
+
+	return fmt.Sprintf("%s: %s is %s: %v", ErrRoleUnavailable, e.Role, e.State, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *RoleLoadError) Unwrap() []error {
+	return []error{ErrRoleUnavailable, e.Err}
+}
+
+// RoleStatus describes a delegated role as seen by the last load
+type RoleStatus struct {
+	State   RoleState  `json:"state"`
+	Version int64      `json:"version,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+	Error   string     `json:"error,omitempty"`
+
+	// SuccinctRoles describes the succinct hash bin delegation of a role
+	// that delegates with one
+	SuccinctRoles *SuccinctRolesStatus `json:"succinct_roles,omitempty"`
+
+	// HashBinOf is the role that delegates to this role as one of its hash
+	// bins, by path hash prefixes or succinctly
+	HashBinOf string `json:"hash_bin_of,omitempty"`
+}
+
+// SuccinctRolesStatus describes a succinct hash bin delegation (TAP 15). Its
+// bins are named NamePrefix followed by the bin number in hex.
+type SuccinctRolesStatus struct {
+	NamePrefix string `json:"name_prefix"`
+	BitLength  int    `json:"bit_length"`
+	// Bins is the number of bins the delegation describes, of which
+	// LoadedBins were listed by the snapshot and loaded
+	Bins       uint64 `json:"bins"`
+	LoadedBins int    `json:"loaded_bins"`
+}
+
+// succinctRolesStatus returns the succinct hash bin delegation of meta, or
+// nil if it delegates without one
+func (c *Client) succinctRolesStatus(meta *metadata.Metadata[metadata.TargetsType]) *SuccinctRolesStatus {
+	if meta.Signed.Delegations == nil || meta.Signed.Delegations.SuccinctRoles == nil {
+		return nil
+	}
+
+	succinct := meta.Signed.Delegations.SuccinctRoles
+	status := &SuccinctRolesStatus{
+		NamePrefix: succinct.NamePrefix,
+		BitLength:  succinct.BitLength,
+	}
+	if validBitLength(succinct) {
+		status.Bins = 1 << succinct.BitLength
+	}
+	for _, bin := range c.succinctBinRoles(succinct) {
+		if _, loaded := c.delegatedMeta[bin.Name]; loaded {
+			status.LoadedBins++
+		}
+	}
+
+	return status
+}
+
+// delegation is an edge of the delegation graph
+type delegation struct {
+	delegator string
+	role      string
+}
+
+// roleUnavailable records that a delegated role's metadata could not be
+// loaded
+func (c *Client) roleUnavailable(roleName string, state RoleState, err error) {
+	c.roleErrors[roleName] = &RoleLoadError{Role: roleName, State: state, Err: err}
+}
+
+// delegationUntrusted records that delegator's delegation to a role cannot
+// be followed because the role's metadata is not signed by the keys
+// delegator assigns to it
+func (c *Client) delegationUntrusted(delegator, roleName string, err error) {
+	c.delegationErrors[delegation{delegator, roleName}] = &RoleLoadError{
+		Role:      roleName,
+		Delegator: delegator,
+		State:     RoleInvalid,
+		Err:       err,
+	}
+}
+
+// LoadErrors returns the delegated roles whose metadata was unavailable at
+// load, sorted by role name and delegator
+func (c *Client) LoadErrors() []*RoleLoadError {
+	loadErrors := make([]*RoleLoadError, 0, len(c.roleErrors)+len(c.delegationErrors))
+	for _, roleErr := range c.roleErrors {
+		loadErrors = append(loadErrors, roleErr)
+	}
+	for _, roleErr := range c.delegationErrors {
+		loadErrors = append(loadErrors, roleErr)
+	}
+	sort.Slice(loadErrors, func(i, j int) bool {
+		if loadErrors[i].Role != loadErrors[j].Role {
+			return loadErrors[i].Role < loadErrors[j].Role
+		}
+		return loadErrors[i].Delegator < loadErrors[j].Delegator
+	})
+
+	return loadErrors
+}
+
+// GetRoleStatus returns the status of every delegated role the trusted
+// metadata delegates to, keyed by role name. A role that some delegators
+// trust is reported as loaded; LoadErrors lists the delegators that do not.
+func (c *Client) GetRoleStatus() map[string]RoleStatus {
+	statuses := make(map[string]RoleStatus, len(c.delegatedMeta)+len(c.roleErrors))
+
+	for _, roleErr := range c.LoadErrors() {
+		if _, reported := statuses[roleErr.Role]; reported {
+			continue
+		}
+		errText := roleErr.Err.Error()
+		if roleErr.Delegator != "" {
+			errText = fmt.Sprintf("delegated by %s: %v", roleErr.Delegator, roleErr.Err)
+		}
+		statuses[roleErr.Role] = RoleStatus{State: roleErr.State, Error: errText}
+	}
+	for roleName, meta := range c.delegatedMeta {
+		statuses[roleName] = RoleStatus{
+			State:         RoleLoaded,
+			Version:       meta.Signed.Version,
+			Expires:       timePointer(meta.Signed.Expires),
+			SuccinctRoles: c.succinctRolesStatus(meta),
+			HashBinOf:     c.hashBinDelegators[roleName],
+		}
+	}
+
+	return statuses
+}
+
+// GetTopLevelStatus returns the status of the top-level roles, keyed by role
+// name
+func (c *Client) GetTopLevelStatus() map[string]RoleStatus {
+	return map[string]RoleStatus{
+		metadata.ROOT:      {State: RoleLoaded, Version: c.rootMeta.Signed.Version, Expires: timePointer(c.rootMeta.Signed.Expires)},
+		metadata.TIMESTAMP: {State: RoleLoaded, Version: c.timestampMeta.Signed.Version, Expires: timePointer(c.timestampMeta.Signed.Expires)},
+		metadata.SNAPSHOT:  {State: RoleLoaded, Version: c.snapshotMeta.Signed.Version, Expires: timePointer(c.snapshotMeta.Signed.Expires)},
+		metadata.TARGETS: {
+			State:         RoleLoaded,
+			Version:       c.targetsMeta.Signed.Version,
+			Expires:       timePointer(c.targetsMeta.Signed.Expires),
+			SuccinctRoles: c.succinctRolesStatus(c.targetsMeta),
+		},
+	}
+}
+
+// timePointer returns a pointer to a copy of t
+func timePointer(t time.Time) *time.Time {
+	return &t
+}
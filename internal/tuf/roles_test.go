@@ -0,0 +1,46 @@
+package tuf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+func TestSuccinctRolesStatus(t *testing.T) {
+	repo := newTestRepo(t, time.Now().Add(24*time.Hour))
+	repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "library", Paths: []string{"/v2/library/*"}})
+	repo.delegateSuccinct("library", "library-hb", 2)
+
+	dir := t.TempDir()
+	repo.write(dir)
+
+	client, err := NewClient(Config{RepoPath: dir})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	want := SuccinctRolesStatus{NamePrefix: "library-hb", BitLength: 2, Bins: 4, LoadedBins: 4}
+	statuses := client.GetRoleStatus()
+	if got := statuses["library"].SuccinctRoles; got == nil || *got != want {
+		t.Errorf("library succinct roles = %+v, want %+v", got, want)
+	}
+	if got := statuses["library-hb-0"].SuccinctRoles; got != nil {
+		t.Errorf("library-hb-0 succinct roles = %+v, want none", got)
+	}
+	if got := statuses["library-hb-0"].HashBinOf; got != "library" {
+		t.Errorf("library-hb-0 hash bin of %q, want library", got)
+	}
+	if got := statuses["library"].HashBinOf; got != "" {
+		t.Errorf("library hash bin of %q, want none", got)
+	}
+	if got := client.GetTopLevelStatus()[metadata.TARGETS].SuccinctRoles; got != nil {
+		t.Errorf("targets succinct roles = %+v, want none", got)
+	}
+
+	// Bins have no path patterns and are not reported as delegations
+	delegations := client.GetDelegationInfo()
+	if len(delegations) != 1 || delegations["library"] == nil {
+		t.Errorf("GetDelegationInfo() = %v, want only library", delegations)
+	}
+}
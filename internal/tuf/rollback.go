@@ -0,0 +1,89 @@
+package tuf
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+// ErrRollback is returned when metadata is older than the version of the same
+// role that was previously trusted
+var ErrRollback = errors.New("metadata version rollback")
+
+// trustedVersion is the last verified version of a role, along with the key
+// IDs it was verified against
+type trustedVersion struct {
+	Version int64    `json:"version"`
+	KeyIDs  []string `json:"keyids"`
+}
+
+// checkRollback returns ErrRollback if version is lower than the trusted
+// version of roleName. A role whose keys have been rotated since is no longer
+// bound by its previous version, which lets a repository recover from a
+// fast-forward attack by rotating keys.
+func (c *Client) checkRollback(roleName string, version int64, keyIDs []string) error {
+	trusted, ok := c.trustedVersions[roleName]
+	if !ok || !slices.Equal(trusted.KeyIDs, sortedKeyIDs(keyIDs)) {
+		return nil
+	}
+
+	if version < trusted.Version {
+		return fmt.Errorf("%w: %s version %d is lower than trusted version %d", ErrRollback, roleName, version, trusted.Version)
+	}
+
+	return nil
+}
+
+// recordVersion records version as the trusted version of roleName
+func (c *Client) recordVersion(roleName string, version int64, keyIDs []string) {
+	c.trustedVersions[roleName] = trustedVersion{
+		Version: version,
+		KeyIDs:  sortedKeyIDs(keyIDs),
+	}
+}
+
+// checkTopLevelRollback checks timestamp, snapshot and targets against their
+// trusted versions and records the loaded versions. As the timestamp pins the
+// snapshot version, rotating either role's keys resets both.
+func (c *Client) checkTopLevelRollback() error {
+	roleKeyIDs := func(roleNames ...string) []string {
+		var keyIDs []string
+		for _, roleName := range roleNames {
+			if role, ok := c.rootMeta.Signed.Roles[roleName]; ok {
+				keyIDs = append(keyIDs, role.KeyIDs...)
+			}
+		}
+		return keyIDs
+	}
+
+	timestampSnapshotKeyIDs := roleKeyIDs(metadata.TIMESTAMP, metadata.SNAPSHOT)
+	versions := []struct {
+		roleName string
+		version  int64
+		keyIDs   []string
+	}{
+		{metadata.TIMESTAMP, c.timestampMeta.Signed.Version, timestampSnapshotKeyIDs},
+		{metadata.SNAPSHOT, c.snapshotMeta.Signed.Version, timestampSnapshotKeyIDs},
+		{metadata.TARGETS, c.targetsMeta.Signed.Version, roleKeyIDs(metadata.TARGETS)},
+	}
+
+	for _, v := range versions {
+		if err := c.checkRollback(v.roleName, v.version, v.keyIDs); err != nil {
+			return err
+		}
+	}
+	for _, v := range versions {
+		c.recordVersion(v.roleName, v.version, v.keyIDs)
+	}
+
+	return nil
+}
+
+// sortedKeyIDs returns a sorted copy of keyIDs without duplicates
+func sortedKeyIDs(keyIDs []string) []string {
+	sorted := slices.Clone(keyIDs)
+	slices.Sort(sorted)
+	return slices.Compact(sorted)
+}
@@ -0,0 +1,103 @@
+package tuf
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+// rotateTargetsKey makes root version 2 assign targets a new key
+func rotateTargetsKey(t *testing.T, repo *testRepo, dir string) {
+	t.Helper()
+
+	oldKey := repo.key(metadata.TARGETS)
+	delete(repo.keys, metadata.TARGETS)
+	if err := repo.root.Signed.RevokeKey(oldKey.ID(), metadata.TARGETS); err != nil {
+		t.Fatalf("failed to revoke targets key: %v", err)
+	}
+	if err := repo.root.Signed.AddKey(repo.key(metadata.TARGETS), metadata.TARGETS); err != nil {
+		t.Fatalf("failed to add targets key: %v", err)
+	}
+	repo.root.Signed.Version = 2
+	repo.sign(metadata.ROOT, repo.root)
+	repo.writeFile(dir, "2.root", repo.root.ToFile)
+}
+
+// rotateLibraryKey makes targets assign library a new key
+func rotateLibraryKey(t *testing.T, repo *testRepo, _ string) {
+	t.Helper()
+
+	delete(repo.keys, "library")
+	key := repo.key("library")
+	delegations := repo.targets[metadata.TARGETS].Signed.Delegations
+	delegations.Keys[key.ID()] = key
+	delegations.Roles[0].KeyIDs = []string{key.ID()}
+	repo.targets[metadata.TARGETS].Signed.Version++
+}
+
+func TestRollback(t *testing.T) {
+	tests := []struct {
+		name    string
+		role    string
+		restart bool
+		rotate  func(t *testing.T, repo *testRepo, dir string)
+		want    error
+	}{
+		{name: "targets on refresh", role: metadata.TARGETS, want: ErrRollback},
+		{name: "delegated role on refresh", role: "library", want: ErrRollback},
+		{name: "targets after restart", role: metadata.TARGETS, restart: true, want: ErrRollback},
+		{name: "delegated role after restart", role: "library", restart: true, want: ErrRollback},
+		{name: "targets key rotated on refresh", role: metadata.TARGETS, rotate: rotateTargetsKey},
+		{name: "targets key rotated after restart", role: metadata.TARGETS, restart: true, rotate: rotateTargetsKey},
+		{name: "delegated key rotated on refresh", role: "library", rotate: rotateLibraryKey},
+		{name: "delegated key rotated after restart", role: "library", restart: true, rotate: rotateLibraryKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepo(t, time.Now().Add(24*time.Hour))
+			repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "library", Paths: []string{"/v2/library/*"}})
+			repo.addTarget("library", "/v2/library/alpine")
+			repo.targets[tt.role].Signed.Version = 2
+
+			dir := t.TempDir()
+			repo.write(dir)
+
+			cfg := Config{RepoPath: dir}
+			if tt.restart {
+				cfg.StateDir = t.TempDir()
+			}
+
+			// Load version 2, then publish version 1 in a newer snapshot
+			var refresher *Refresher
+			var err error
+			if tt.restart {
+				_, err = NewClient(cfg)
+			} else {
+				refresher, err = NewRefresher(cfg)
+			}
+			if err != nil {
+				t.Fatalf("initial load error = %v", err)
+			}
+
+			repo.targets[tt.role].Signed.Version = 1
+			if tt.rotate != nil {
+				tt.rotate(t, repo, dir)
+			}
+			repo.snapshot.Signed.Version++
+			repo.timestamp.Signed.Version++
+			repo.write(dir)
+
+			if tt.restart {
+				_, err = NewClient(cfg)
+			} else {
+				_, err = refresher.Refresh()
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("reload error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package tuf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+// maxRootRotations bounds how many root versions a single load will walk
+const maxRootRotations = 256
+
+// RootInfo describes the trusted root metadata
+type RootInfo struct {
+	Version            int64
+	Expires            time.Time
+	ConsistentSnapshot bool
+}
+
+// loadTrustedRoot loads the initial trusted root and walks the versioned
+// N.root.json chain in the repository up to the newest root it can verify. It
+// returns that root along with its raw bytes, which are persisted only once
+// the rest of the metadata has been verified against it.
+func loadTrustedRoot(cfg Config) (*metadata.Metadata[metadata.RootType], []byte, error) {
+	rootBytes, err := readInitialRoot(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read root metadata: %w", err)
+	}
+
+	rootMeta := &metadata.Metadata[metadata.RootType]{}
+	if err := json.Unmarshal(rootBytes, rootMeta); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse root metadata: %w", err)
+	}
+
+	// Root must be signed by a threshold of its own root keys
+	if err := verifyTopLevel(rootMeta, metadata.ROOT, rootMeta.Signed.Type, rootMeta); err != nil {
+		return nil, nil, fmt.Errorf("failed to verify root metadata: %w", err)
+	}
+
+	for i := 0; i < maxRootRotations; i++ {
+		nextVersion := rootMeta.Signed.Version + 1
+		nextBytes, err := os.ReadFile(filepath.Join(cfg.RepoPath, fmt.Sprintf("%d.root.json", nextVersion)))
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read root metadata version %d: %w", nextVersion, err)
+		}
+
+		nextRoot, err := rotateRoot(rootMeta, nextBytes, nextVersion)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to rotate to root metadata version %d: %w", nextVersion, err)
+		}
+		rootMeta, rootBytes = nextRoot, nextBytes
+	}
+
+	return rootMeta, rootBytes, nil
+}
+
+// readInitialRoot returns the root the chain walk starts from: the newest of
+// the configured anchor (Config.RootBytes, or else the repository's root.json)
+// and the root persisted in the state directory
+func readInitialRoot(cfg Config) ([]byte, error) {
+	anchorBytes := cfg.RootBytes
+	if len(anchorBytes) == 0 {
+		var err error
+		anchorBytes, err = os.ReadFile(filepath.Join(cfg.RepoPath, "root.json"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.StateDir == "" {
+		return anchorBytes, nil
+	}
+
+	stateBytes, err := os.ReadFile(statePath(cfg.StateDir, "root.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return anchorBytes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A newer anchor (e.g. a rebuilt binary) supersedes older persisted state
+	if rootVersion(anchorBytes) > rootVersion(stateBytes) {
+		return anchorBytes, nil
+	}
+
+	return stateBytes, nil
+}
+
+// rootVersion returns the version of the given root metadata, or 0 if it
+// cannot be parsed
+func rootVersion(rootBytes []byte) int64 {
+	rootMeta := &metadata.Metadata[metadata.RootType]{}
+	if err := json.Unmarshal(rootBytes, rootMeta); err != nil {
+		return 0
+	}
+
+	return rootMeta.Signed.Version
+}
+
+// rotateRoot verifies nextBytes as root version nextVersion against the
+// currently trusted root. Per the TUF specification the new root must be
+// signed by a threshold of both the trusted and its own root keys.
+func rotateRoot(trusted *metadata.Metadata[metadata.RootType], nextBytes []byte, nextVersion int64) (*metadata.Metadata[metadata.RootType], error) {
+	nextRoot := &metadata.Metadata[metadata.RootType]{}
+	if err := json.Unmarshal(nextBytes, nextRoot); err != nil {
+		return nil, fmt.Errorf("failed to parse root metadata: %w", err)
+	}
+
+	if err := verifyTopLevel(trusted, metadata.ROOT, nextRoot.Signed.Type, nextRoot); err != nil {
+		return nil, fmt.Errorf("not signed by trusted root keys: %w", err)
+	}
+
+	if err := verifyTopLevel(nextRoot, metadata.ROOT, nextRoot.Signed.Type, nextRoot); err != nil {
+		return nil, fmt.Errorf("not signed by its own root keys: %w", err)
+	}
+
+	if nextRoot.Signed.Version != nextVersion {
+		return nil, fmt.Errorf("file contains root version %d", nextRoot.Signed.Version)
+	}
+
+	return nextRoot, nil
+}
+
+// GetRootInfo returns information about the trusted root metadata
+func (c *Client) GetRootInfo() RootInfo {
+	return RootInfo{
+		Version:            c.rootMeta.Signed.Version,
+		Expires:            c.rootMeta.Signed.Expires,
+		ConsistentSnapshot: c.rootMeta.Signed.ConsistentSnapshot,
+	}
+}
@@ -0,0 +1,88 @@
+package tuf
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// trustedVersionsFile is the state file recording the last verified version
+// of every targets, snapshot and timestamp role
+const trustedVersionsFile = "versions.json"
+
+// statePath returns the path of name inside the trusted state directory
+func statePath(stateDir, name string) string {
+	return filepath.Join(stateDir, name)
+}
+
+// persistRoot records rootBytes as the newest trusted root in the state
+// directory, unless the persisted root is already as new
+func persistRoot(stateDir string, rootBytes []byte) error {
+	name := statePath(stateDir, "root.json")
+	if stateBytes, err := os.ReadFile(name); err == nil && rootVersion(stateBytes) >= rootVersion(rootBytes) {
+		return nil
+	}
+
+	return writeFileAtomic(name, rootBytes)
+}
+
+// loadTrustedVersions reads the trusted role versions from the state
+// directory. A missing file yields an empty set.
+func loadTrustedVersions(stateDir string) (map[string]trustedVersion, error) {
+	versions := make(map[string]trustedVersion)
+
+	data, err := os.ReadFile(statePath(stateDir, trustedVersionsFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return versions, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &versions); err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+// persistTrustedVersions records the trusted role versions in the state
+// directory
+func persistTrustedVersions(stateDir string, versions map[string]trustedVersion) error {
+	data, err := json.MarshalIndent(versions, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(statePath(stateDir, trustedVersionsFile), data)
+}
+
+// writeFileAtomic writes data to a temporary file next to name and renames it
+// into place, so readers never observe a partially written file
+func writeFileAtomic(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
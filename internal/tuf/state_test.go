@@ -0,0 +1,58 @@
+package tuf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+func TestRootPersistedOnlyAfterLoadSucceeds(t *testing.T) {
+	repo := newTestRepo(t, time.Now().Add(24*time.Hour))
+	dir := t.TempDir()
+	stateDir := t.TempDir()
+	repo.write(dir)
+
+	cfg := Config{RepoPath: dir, StateDir: stateDir}
+	if _, err := NewClient(cfg); err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	assertPersistedRootVersion(t, stateDir, 1)
+
+	// Rotate to root version 2, but publish a timestamp it does not vouch
+	// for
+	repo.root.Signed.Version = 2
+	repo.sign(metadata.ROOT, repo.root)
+	repo.writeFile(dir, "2.root", repo.root.ToFile)
+	repo.timestamp.Signed.Version++
+	if err := repo.timestamp.ToFile(filepath.Join(dir, "timestamp.json"), true); err != nil {
+		t.Fatalf("failed to write timestamp: %v", err)
+	}
+
+	if _, err := NewClient(cfg); err == nil {
+		t.Fatal("NewClient() succeeded with a tampered timestamp")
+	}
+	assertPersistedRootVersion(t, stateDir, 1)
+
+	repo.write(dir)
+	if _, err := NewClient(cfg); err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	assertPersistedRootVersion(t, stateDir, 2)
+}
+
+// assertPersistedRootVersion fails t unless the state directory holds root
+// metadata of the given version
+func assertPersistedRootVersion(t *testing.T, stateDir string, want int64) {
+	t.Helper()
+
+	rootBytes, err := os.ReadFile(statePath(stateDir, "root.json"))
+	if err != nil {
+		t.Fatalf("failed to read persisted root: %v", err)
+	}
+	if got := rootVersion(rootBytes); got != want {
+		t.Errorf("persisted root version = %d, want %d", got, want)
+	}
+}
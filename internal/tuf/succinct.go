@@ -0,0 +1,54 @@
+package tuf
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+// delegatedRoles returns the roles to load for a set of delegations. Succinct
+// hash bin delegations (TAP 15) are expanded into one role per bin.
+func (c *Client) delegatedRoles(delegations *metadata.Delegations) []metadata.DelegatedRole {
+	if delegations.SuccinctRoles != nil {
+		return c.succinctBinRoles(delegations.SuccinctRoles)
+	}
+
+	return delegations.Roles
+}
+
+// validBitLength reports whether a succinct delegation's bit_length is within
+// the 1-32 range TAP 15 allows
+func validBitLength(succinct *metadata.SuccinctRoles) bool {
+	return succinct.BitLength >= 1 && succinct.BitLength <= 32
+}
+
+// succinctBinRoles returns the bins of a succinct delegation that the trusted
+// snapshot lists, sorted by name. Only listed bins can be loaded, and a
+// delegation may describe up to 2^32 bins, so they are never enumerated.
+func (c *Client) succinctBinRoles(succinct *metadata.SuccinctRoles) []metadata.DelegatedRole {
+	if !validBitLength(succinct) {
+		return nil
+	}
+
+	var binNames []string
+	for fileName := range c.snapshotMeta.Signed.Meta {
+		binName := strings.TrimSuffix(fileName, ".json")
+		if succinct.IsDelegatedRole(binName) {
+			binNames = append(binNames, binName)
+		}
+	}
+	sort.Strings(binNames)
+
+	roles := make([]metadata.DelegatedRole, 0, len(binNames))
+	for _, binName := range binNames {
+		roles = append(roles, metadata.DelegatedRole{
+			Name:        binName,
+			KeyIDs:      succinct.KeyIDs,
+			Threshold:   succinct.Threshold,
+			Terminating: true,
+		})
+	}
+
+	return roles
+}
@@ -0,0 +1,184 @@
+package tuf
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+// testRepo builds signed repositories for tests. Every role is signed by a
+// key of its own.
+type testRepo struct {
+	t         testing.TB
+	root      *metadata.Metadata[metadata.RootType]
+	timestamp *metadata.Metadata[metadata.TimestampType]
+	snapshot  *metadata.Metadata[metadata.SnapshotType]
+	targets   map[string]*metadata.Metadata[metadata.TargetsType]
+	keys      map[string]ed25519.PrivateKey
+}
+
+// newTestRepo returns a repository whose top-level roles all expire at
+// expires and that delegates nothing yet
+func newTestRepo(t testing.TB, expires time.Time) *testRepo {
+	t.Helper()
+
+	r := &testRepo{
+		t:         t,
+		root:      metadata.Root(expires),
+		timestamp: metadata.Timestamp(expires),
+		snapshot:  metadata.Snapshot(expires),
+		targets:   map[string]*metadata.Metadata[metadata.TargetsType]{metadata.TARGETS: metadata.Targets(expires)},
+		keys:      make(map[string]ed25519.PrivateKey),
+	}
+	r.root.Signed.ConsistentSnapshot = false
+
+	for _, roleName := range []string{metadata.ROOT, metadata.TIMESTAMP, metadata.SNAPSHOT, metadata.TARGETS} {
+		if err := r.root.Signed.AddKey(r.key(roleName), roleName); err != nil {
+			t.Fatalf("failed to add %s key: %v", roleName, err)
+		}
+	}
+
+	return r
+}
+
+// key returns the public key of roleName, generating its key pair on first
+// use
+func (r *testRepo) key(roleName string) *metadata.Key {
+	r.t.Helper()
+
+	if _, ok := r.keys[roleName]; !ok {
+		_, privateKey, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			r.t.Fatalf("failed to generate %s key: %v", roleName, err)
+		}
+		r.keys[roleName] = privateKey
+	}
+
+	key, err := metadata.KeyFromPublicKey(r.keys[roleName].Public())
+	if err != nil {
+		r.t.Fatalf("failed to convert %s key: %v", roleName, err)
+	}
+
+	return key
+}
+
+// delegate adds role to the delegations of delegator, signed by the role's
+// own key, and creates the role's metadata if it does not exist yet
+func (r *testRepo) delegate(delegator string, role metadata.DelegatedRole) {
+	r.t.Helper()
+
+	delegatorMeta := r.targets[delegator]
+	if delegatorMeta.Signed.Delegations == nil {
+		delegatorMeta.Signed.Delegations = &metadata.Delegations{Keys: make(map[string]*metadata.Key)}
+	}
+
+	key := r.key(role.Name)
+	delegatorMeta.Signed.Delegations.Keys[key.ID()] = key
+	role.KeyIDs = []string{key.ID()}
+	if role.Threshold == 0 {
+		role.Threshold = 1
+	}
+	delegatorMeta.Signed.Delegations.Roles = append(delegatorMeta.Signed.Delegations.Roles, role)
+
+	if _, ok := r.targets[role.Name]; !ok {
+		r.targets[role.Name] = metadata.Targets(delegatorMeta.Signed.Expires)
+	}
+}
+
+// delegateSuccinct makes delegator delegate to 2^bitLength succinct hash
+// bins named with namePrefix, all signed by one key, and creates their
+// metadata
+func (r *testRepo) delegateSuccinct(delegator, namePrefix string, bitLength int) *metadata.SuccinctRoles {
+	r.t.Helper()
+
+	delegatorMeta := r.targets[delegator]
+	key := r.key(namePrefix)
+	succinct := &metadata.SuccinctRoles{
+		KeyIDs:     []string{key.ID()},
+		Threshold:  1,
+		BitLength:  bitLength,
+		NamePrefix: namePrefix,
+	}
+	delegatorMeta.Signed.Delegations = &metadata.Delegations{
+		Keys:          map[string]*metadata.Key{key.ID(): key},
+		SuccinctRoles: succinct,
+	}
+
+	for _, binName := range succinct.GetRoles() {
+		r.keys[binName] = r.keys[namePrefix]
+		r.targets[binName] = metadata.Targets(delegatorMeta.Signed.Expires)
+	}
+
+	return succinct
+}
+
+// addTarget lists path as a target of roleName
+func (r *testRepo) addTarget(roleName, path string) {
+	r.t.Helper()
+
+	targetFile, err := metadata.TargetFile().FromBytes(path, []byte(path), "sha256")
+	if err != nil {
+		r.t.Fatalf("failed to create target %s: %v", path, err)
+	}
+	r.targets[roleName].Signed.Targets[path] = targetFile
+}
+
+// write signs every role and writes the repository to dir, listing every
+// targets role in the snapshot
+func (r *testRepo) write(dir string) {
+	r.t.Helper()
+
+	r.snapshot.Signed.Meta = make(map[string]*metadata.MetaFiles)
+	for roleName, meta := range r.targets {
+		r.sign(roleName, meta)
+		r.writeFile(dir, roleName, meta.ToFile)
+		r.snapshot.Signed.Meta[roleName+".json"] = metadata.MetaFile(meta.Signed.Version)
+	}
+
+	r.timestamp.Signed.Meta = map[string]*metadata.MetaFiles{
+		"snapshot.json": metadata.MetaFile(r.snapshot.Signed.Version),
+	}
+
+	r.sign(metadata.SNAPSHOT, r.snapshot)
+	r.writeFile(dir, metadata.SNAPSHOT, r.snapshot.ToFile)
+	r.sign(metadata.TIMESTAMP, r.timestamp)
+	r.writeFile(dir, metadata.TIMESTAMP, r.timestamp.ToFile)
+	r.sign(metadata.ROOT, r.root)
+	r.writeFile(dir, metadata.ROOT, r.root.ToFile)
+}
+
+// writeFile writes a role's metadata to dir with toFile
+func (r *testRepo) writeFile(dir, roleName string, toFile func(string, bool) error) {
+	r.t.Helper()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		r.t.Fatalf("failed to create repository directory: %v", err)
+	}
+	if err := toFile(filepath.Join(dir, roleName+".json"), true); err != nil {
+		r.t.Fatalf("failed to write %s metadata: %v", roleName, err)
+	}
+}
+
+// sign replaces the signatures on meta with one by roleName's key
+func (r *testRepo) sign(roleName string, meta interface {
+	ClearSignatures()
+	Sign(signature.Signer) (*metadata.Signature, error)
+}) {
+	r.t.Helper()
+
+	signer, err := signature.LoadSigner(r.keys[roleName], crypto.Hash(0))
+	if err != nil {
+		r.t.Fatalf("failed to load %s signer: %v", roleName, err)
+	}
+
+	meta.ClearSignatures()
+	if _, err := meta.Sign(signer); err != nil {
+		r.t.Fatalf("failed to sign %s metadata: %v", roleName, err)
+	}
+}
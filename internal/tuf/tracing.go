@@ -0,0 +1,70 @@
+package tuf
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of metadata loads and decisions. It uses the
+// global tracer provider, so spans are only recorded once one is installed.
+var tracer = otel.Tracer("github.com/matglas/tuf-client-verify/internal/tuf")
+
+// traceLoad runs load in a span called name and records the versions of the
+// loaded metadata on it
+func traceLoad(ctx context.Context, name string, load func() (*Client, error)) (*Client, error) {
+	_, span := tracer.Start(ctx, name)
+	defer span.End()
+
+	client, err := load()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return client, err
+	}
+
+	span.SetAttributes(
+		attribute.Int64("tuf.root.version", client.rootMeta.Signed.Version),
+		attribute.Int64("tuf.timestamp.version", client.timestampMeta.Signed.Version),
+		attribute.Int64("tuf.snapshot.version", client.snapshotMeta.Signed.Version),
+		attribute.Int64("tuf.targets.version", client.targetsMeta.Signed.Version),
+		attribute.Int("tuf.delegated_roles", len(client.delegatedMeta)),
+		attribute.Int("tuf.unavailable_roles", len(client.LoadErrors())),
+	)
+
+	return client, nil
+}
+
+// traceRole starts the span of consulting visit during a delegation search.
+// Searches that are not recorded get a span that records nothing, so
+// unsampled decisions do not pay for a span per role.
+func traceRole(ctx context.Context, visit roleVisit) trace.Span {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return trace.SpanFromContext(context.Background())
+	}
+
+	_, span := tracer.Start(ctx, "tuf.Consult", trace.WithAttributes(
+		attribute.String("tuf.role", visit.role.Role),
+		attribute.String("tuf.delegator", visit.role.Delegator),
+		attribute.Int("tuf.depth", visit.depth),
+	))
+
+	return span
+}
+
+// traceDecision records decision on span. The consulted roles are recorded
+// too, as cached decisions and index hits have no tuf.Consult spans.
+func traceDecision(span trace.Span, decision Decision, cached bool) {
+	span.SetAttributes(
+		attribute.Bool("tuf.allowed", decision.Allowed),
+		attribute.Bool("tuf.cached", cached),
+		attribute.StringSlice("tuf.role_chain", decision.RoleChain),
+		attribute.StringSlice("tuf.consulted_roles", decision.ConsultedRoles),
+	)
+	if !decision.Allowed {
+		span.SetAttributes(attribute.String("tuf.deny_reason", string(decision.Reason)))
+	}
+}
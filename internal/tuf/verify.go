@@ -0,0 +1,57 @@
+package tuf
+
+import (
+	"fmt"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+// verifyTopLevel checks that meta is of the expected top-level role type and
+// carries a threshold of valid signatures from the keys root assigns to it
+func verifyTopLevel(root *metadata.Metadata[metadata.RootType], roleName, metaType string, meta any) error {
+	if metaType != roleName {
+		return fmt.Errorf("expected metadata type %s, got %q", roleName, metaType)
+	}
+
+	role, ok := root.Signed.Roles[roleName]
+	if !ok {
+		return fmt.Errorf("root does not define role %s", roleName)
+	}
+	if role.Threshold < 1 {
+		return fmt.Errorf("root defines invalid threshold %d for role %s", role.Threshold, roleName)
+	}
+
+	return root.VerifyDelegate(roleName, meta)
+}
+
+// verifyDelegated checks that meta is targets metadata carrying a threshold
+// of valid signatures from the keys delegator assigns to the delegated role
+func verifyDelegated(delegator *metadata.Metadata[metadata.TargetsType], role metadata.DelegatedRole, meta *metadata.Metadata[metadata.TargetsType]) error {
+	if meta.Signed.Type != metadata.TARGETS {
+		return fmt.Errorf("expected metadata type %s, got %q", metadata.TARGETS, meta.Signed.Type)
+	}
+	if role.Threshold < 1 {
+		return fmt.Errorf("invalid threshold %d for delegated role %s", role.Threshold, role.Name)
+	}
+
+	return delegator.VerifyDelegate(role.Name, meta)
+}
+
+// verifyMetaFile checks that data is the version of fileName recorded in the
+// signed meta map, including its length and hashes when those are present
+func verifyMetaFile(meta map[string]*metadata.MetaFiles, fileName string, version int64, data []byte) error {
+	info, ok := meta[fileName]
+	if !ok || info == nil {
+		return fmt.Errorf("%s is not listed in signed metadata", fileName)
+	}
+
+	if err := info.VerifyLengthHashes(data); err != nil {
+		return fmt.Errorf("%s: %w", fileName, err)
+	}
+
+	if version != info.Version {
+		return fmt.Errorf("%s has version %d, expected %d", fileName, version, info.Version)
+	}
+
+	return nil
+}
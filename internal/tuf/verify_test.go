@@ -0,0 +1,86 @@
+package tuf
+
+import (
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+func TestMetaFileMismatchRejected(t *testing.T) {
+	otherHash := sha256.Sum256([]byte("other"))
+	tampers := []struct {
+		name   string
+		tamper func(info *metadata.MetaFiles)
+	}{
+		{"version", func(info *metadata.MetaFiles) { info.Version = 7 }},
+		{"length", func(info *metadata.MetaFiles) { info.Length = 1 }},
+		{"hash", func(info *metadata.MetaFiles) {
+			info.Hashes = map[string]metadata.HexBytes{"sha256": otherHash[:]}
+		}},
+	}
+	files := []struct {
+		name      string
+		listedBy  string
+		delegated bool
+	}{
+		{name: "snapshot.json", listedBy: metadata.TIMESTAMP},
+		{name: "targets.json", listedBy: metadata.SNAPSHOT},
+		{name: "library.json", listedBy: metadata.SNAPSHOT, delegated: true},
+	}
+
+	for _, file := range files {
+		for _, tt := range tampers {
+			t.Run(file.name+"/"+tt.name, func(t *testing.T) {
+				repo := newTestRepo(t, time.Now().Add(24*time.Hour))
+				repo.delegate(metadata.TARGETS, metadata.DelegatedRole{Name: "library", Paths: []string{"/v2/library/*"}})
+				repo.addTarget("library", "/v2/library/alpine")
+
+				dir := t.TempDir()
+				repo.write(dir)
+
+				// Re-sign the listing role with a record the file does not
+				// match
+				if file.listedBy == metadata.TIMESTAMP {
+					tt.tamper(repo.timestamp.Signed.Meta[file.name])
+					repo.sign(metadata.TIMESTAMP, repo.timestamp)
+					repo.writeFile(dir, metadata.TIMESTAMP, repo.timestamp.ToFile)
+				} else {
+					tt.tamper(repo.snapshot.Signed.Meta[file.name])
+					repo.sign(metadata.SNAPSHOT, repo.snapshot)
+					repo.writeFile(dir, metadata.SNAPSHOT, repo.snapshot.ToFile)
+				}
+
+				// A delegated role that does not match the snapshot is
+				// unavailable and fails strict loading
+				client, err := NewClient(Config{RepoPath: dir, StrictDelegations: file.delegated})
+				if err == nil {
+					t.Fatalf("NewClient() succeeded with a %s that does not match %s", file.name, file.listedBy)
+				}
+				if !strings.Contains(err.Error(), "does not match") {
+					t.Errorf("NewClient() error = %v, want a mismatch with %s", err, file.listedBy)
+				}
+				if !file.delegated {
+					return
+				}
+				if !errors.Is(err, ErrRoleUnavailable) {
+					t.Errorf("NewClient() error = %v, want %v", err, ErrRoleUnavailable)
+				}
+
+				client, err = NewClient(Config{RepoPath: dir})
+				if err != nil {
+					t.Fatalf("NewClient() error = %v", err)
+				}
+				if status := client.GetRoleStatus()["library"]; status.State != RoleInvalid {
+					t.Errorf("library status = %+v, want invalid", status)
+				}
+				if allowed, err := client.VerifyPath("/v2/library/alpine"); err != nil || allowed {
+					t.Errorf("VerifyPath() = %v, %v, want denied", allowed, err)
+				}
+			})
+		}
+	}
+}
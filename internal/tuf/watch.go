@@ -0,0 +1,222 @@
+package tuf
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// DefaultReloadDebounce is the default quiet period after a repository
+	// change before reloading
+	DefaultReloadDebounce = 500 * time.Millisecond
+	// DefaultPollInterval is the default interval for polling a repository
+	// for changes
+	DefaultPollInterval = 5 * time.Second
+)
+
+// WatchMode selects how a Refresher watches a local repository for changes
+type WatchMode int
+
+const (
+	// WatchAuto uses filesystem notifications and falls back to polling
+	// when they are unavailable
+	WatchAuto WatchMode = iota
+	// WatchNotify uses filesystem notifications (inotify on Linux)
+	WatchNotify
+	// WatchPoll periodically scans the repository directory, which also
+	// works on network filesystems that do not deliver notifications
+	WatchPoll
+	// WatchOff disables watching
+	WatchOff
+)
+
+// String returns the configuration name of the mode
+func (m WatchMode) String() string {
+	switch m {
+	case WatchAuto:
+		return "auto"
+	case WatchNotify:
+		return "notify"
+	case WatchPoll:
+		return "poll"
+	case WatchOff:
+		return "off"
+	default:
+		return fmt.Sprintf("WatchMode(%d)", int(m))
+	}
+}
+
+// ParseWatchMode parses a mode name as returned by WatchMode.String
+func ParseWatchMode(name string) (WatchMode, error) {
+	switch name {
+	case "", "auto":
+		return WatchAuto, nil
+	case "notify":
+		return WatchNotify, nil
+	case "poll":
+		return WatchPoll, nil
+	case "off":
+		return WatchOff, nil
+	default:
+		return WatchAuto, fmt.Errorf("unknown watch mode: %s", name)
+	}
+}
+
+// StartWatch watches the local repository directory and refreshes the
+// client once changes have been quiet for ReloadDebounce, so a burst of file
+// writes causes a single reload. A reload of a partially written repository
+// fails verification and keeps the current client; the next change retries.
+// onRefresh, if set, is called after each reload. StartWatch returns the mode
+// in effect, which is WatchOff in remote mode, and stops watching when ctx is
+// done.
+func (r *Refresher) StartWatch(ctx context.Context, onRefresh func(*Client, error)) (WatchMode, error) {
+	mode := r.cfg.WatchMode
+	if r.cfg.MetadataURL != "" || mode == WatchOff {
+		return WatchOff, nil
+	}
+
+	changes := make(chan struct{}, 1)
+
+	if mode == WatchAuto || mode == WatchNotify {
+		err := r.watchNotify(ctx, changes)
+		if err == nil {
+			mode = WatchNotify
+		} else if mode == WatchNotify {
+			return WatchOff, fmt.Errorf("failed to watch repository: %w", err)
+		} else {
+			mode = WatchPoll
+		}
+	}
+
+	if mode == WatchPoll {
+		go r.watchPoll(ctx, changes, dirFingerprint(r.cfg.RepoPath))
+	}
+
+	go r.reloadOnChange(ctx, changes, onRefresh)
+
+	return mode, nil
+}
+
+// watchNotify signals changes to the repository directory reported by
+// filesystem notifications
+func (r *Refresher) watchNotify(ctx context.Context, changes chan<- struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if err := watcher.Add(r.cfg.RepoPath); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op != fsnotify.Chmod {
+					signalChange(changes)
+				}
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				// Events may have been lost, e.g. on queue overflow
+				signalChange(changes)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// watchPoll signals changes to the repository directory found by comparing
+// directory listings every PollInterval, starting from the given fingerprint
+func (r *Refresher) watchPoll(ctx context.Context, changes chan<- struct{}, last uint64) {
+	interval := r.cfg.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if current := dirFingerprint(r.cfg.RepoPath); current != last {
+			last = current
+			signalChange(changes)
+		}
+	}
+}
+
+// reloadOnChange refreshes the client once no change has been signalled for
+// ReloadDebounce
+func (r *Refresher) reloadOnChange(ctx context.Context, changes <-chan struct{}, onRefresh func(*Client, error)) {
+	debounce := r.cfg.ReloadDebounce
+	if debounce <= 0 {
+		debounce = DefaultReloadDebounce
+	}
+
+	var quiet <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changes:
+			quiet = time.After(debounce)
+		case <-quiet:
+			quiet = nil
+			client, err := r.Refresh()
+			if onRefresh != nil {
+				onRefresh(client, err)
+			}
+		}
+	}
+}
+
+// signalChange records a pending change without blocking
+func signalChange(changes chan<- struct{}) {
+	select {
+	case changes <- struct{}{}:
+	default:
+	}
+}
+
+// dirFingerprint summarizes the names, sizes and modification times of the
+// entries in dir
+func dirFingerprint(dir string) uint64 {
+	hash := fnv.New64a()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		fmt.Fprintf(hash, "error %v", err)
+		return hash.Sum64()
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(hash, "%s %d %d\n", entry.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+
+	return hash.Sum64()
+}
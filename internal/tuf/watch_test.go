@@ -0,0 +1,66 @@
+package tuf
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchReloadsOnceAfterBurst(t *testing.T) {
+	const debounce = 200 * time.Millisecond
+
+	for _, mode := range []WatchMode{WatchNotify, WatchPoll} {
+		t.Run(mode.String(), func(t *testing.T) {
+			refresher, _, dir := newTestRefresher(t, Config{
+				WatchMode:      mode,
+				ReloadDebounce: debounce,
+				PollInterval:   10 * time.Millisecond,
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			reloads := make(chan error, 10)
+			got, err := refresher.StartWatch(ctx, func(_ *Client, err error) {
+				reloads <- err
+			})
+			if err != nil {
+				t.Fatalf("StartWatch() error = %v", err)
+			}
+			if got != mode {
+				t.Fatalf("StartWatch() mode = %v, want %v", got, mode)
+			}
+
+			// Each write lands well within the debounce period of the last
+			for i := 0; i < 5; i++ {
+				name := filepath.Join(dir, fmt.Sprintf("%d.unrelated.json", i))
+				if err := os.WriteFile(name, []byte("{}"), 0644); err != nil {
+					t.Fatalf("failed to write %s: %v", name, err)
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+
+			select {
+			case err := <-reloads:
+				if err != nil {
+					t.Fatalf("reload error = %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("repository changes did not trigger a reload")
+			}
+
+			select {
+			case <-reloads:
+				t.Error("a burst of changes triggered more than one reload")
+			case <-time.After(3 * debounce):
+			}
+
+			if status := refresher.Status(); status.Successes != 1 {
+				t.Errorf("Status() successes = %d, want 1", status.Successes)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Package tuftest generates and serves TUF repositories for tests
+package tuftest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// GenerateRepository runs scripts/generate-tuf-repo.go with args and returns
+// the directory of the generated repository and its root of trust. It skips
+// t in short mode and when the go tool is missing.
+func GenerateRepository(t testing.TB, args ...string) (string, []byte) {
+	t.Helper()
+
+	if testing.Short() {
+		t.Skip("skipping repository generation in short mode")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+
+	dir := t.TempDir()
+	repoDir := filepath.Join(dir, "repository")
+	rootPath := filepath.Join(dir, "root.json")
+
+	cmd := exec.Command(goTool, append([]string{"run", "scripts/generate-tuf-repo.go",
+		"-repository-dir", repoDir, "-embedded-root", rootPath}, args...)...)
+	cmd.Dir = moduleRoot()
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to generate repository: %v\n%s", err, output)
+	}
+
+	rootBytes, err := os.ReadFile(rootPath)
+	if err != nil {
+		t.Fatalf("failed to read generated root: %v", err)
+	}
+
+	return repoDir, rootBytes
+}
+
+// ServeRepository serves repoDir over HTTP until t completes and returns its
+// URL
+func ServeRepository(t testing.TB, repoDir string) string {
+	t.Helper()
+
+	server := httptest.NewServer(http.FileServer(http.Dir(repoDir)))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+// moduleRoot returns the root directory of the module, which holds the
+// generator script
+func moduleRoot() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..")
+}
@@ -15,11 +15,16 @@
 // SPDX-License-Identifier: Apache-2.0
 //
 
+//go:build ignore
+
 package main
 
 import (
 	"crypto"
 	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -33,15 +38,33 @@ import (
 // A TUF repository generator for tuf-client-verify testing.
 // Creates a repository with delegation for /v2/library/* paths.
 
+// embeddedRootPath is where the service picks up its compiled-in root of trust
+const embeddedRootPath = "internal/trustroot/embedded/root.json"
+
 func main() {
-	// Create testdata directory if it doesn't exist
-	testdataDir := "testdata"
-	if err := os.MkdirAll(testdataDir, 0755); err != nil {
-		panic(fmt.Sprintf("Failed to create testdata directory: %v", err))
+	hashBins := flag.Int("hash-bins", 0, "distribute the /v2/library/* targets over N path_hash_prefixes bin roles delegated by registry-library (power of 2, at most 256)")
+	succinctBitLength := flag.Int("succinct-bit-length", 0, "distribute the /v2/library/* targets over 2^B succinct hash bin roles (TAP 15) delegated by registry-library (1-16)")
+	consistentSnapshot := flag.Bool("consistent-snapshot", true, "enable consistent snapshots in root and also write snapshot, targets and delegated metadata as <version>.<role>.json")
+	syntheticTargets := flag.Int("synthetic-targets", 0, "add M synthetic /v2/library/* targets to registry-library, hashed from generated content without writing target files")
+	repositoryDir := flag.String("repository-dir", filepath.Join("testdata", "repository"), "directory the repository is written to")
+	embeddedRoot := flag.String("embedded-root", embeddedRootPath, "path the service's compiled-in root of trust is written to")
+	flag.Parse()
+
+	if *hashBins < 0 || *hashBins > 256 || *hashBins&(*hashBins-1) != 0 {
+		panic(fmt.Sprintf("Invalid -hash-bins %d: must be a power of 2 no larger than 256", *hashBins))
+	}
+	if *succinctBitLength < 0 || *succinctBitLength > 16 {
+		panic(fmt.Sprintf("Invalid -succinct-bit-length %d: must be between 1 and 16", *succinctBitLength))
+	}
+	if *hashBins > 0 && *succinctBitLength > 0 {
+		panic("-hash-bins and -succinct-bit-length are mutually exclusive")
+	}
+	if *syntheticTargets < 0 {
+		panic(fmt.Sprintf("Invalid -synthetic-targets %d: must not be negative", *syntheticTargets))
 	}
 
 	// Create repository metadata output directory
-	repoDir := filepath.Join(testdataDir, "repository")
+	repoDir := *repositoryDir
 	if err := os.MkdirAll(repoDir, 0755); err != nil {
 		panic(fmt.Sprintf("Failed to create repository directory: %v", err))
 	}
@@ -77,6 +100,7 @@ func main() {
 
 	// Create root metadata and keys for all top-level roles
 	root := metadata.Root(expireIn(365))
+	root.Signed.ConsistentSnapshot = *consistentSnapshot
 	roles.SetRoot(root)
 
 	// Generate keys and register them with root
@@ -138,6 +162,17 @@ func main() {
 		delegatee.Signed.Targets[targetPath] = targetFileInfo
 	}
 
+	// Add synthetic targets for exercising large delegations
+	for i := 0; i < *syntheticTargets; i++ {
+		targetPath := fmt.Sprintf("/v2/library/synthetic-%d/manifests/latest", i)
+		targetFileInfo, err := metadata.TargetFile().FromBytes(targetPath, []byte(targetPath), "sha256")
+		if err != nil {
+			panic(fmt.Sprintf("Failed to generate target file info for %s: %v", targetPath, err))
+		}
+
+		delegatee.Signed.Targets[targetPath] = targetFileInfo
+	}
+
 	// Set up delegation in top-level targets
 	delegateeKey, err := metadata.KeyFromPublicKey(delegateePrivateKey.Public())
 	if err != nil {
@@ -154,20 +189,46 @@ func main() {
 				KeyIDs:      []string{delegateeKey.ID()},
 				Threshold:   1,
 				Terminating: true,
-				Paths:       []string{"/v2/library/*"},
+				// "*" does not cross "/", so each level of the registry
+				// API path is matched explicitly
+				Paths: []string{
+					"/v2/library/*/manifests/*",
+					"/v2/library/*/blobs/*",
+				},
 			},
 		},
 	}
 
+	// All delegated roles that need to be signed, written and verified
+	delegatedRoles := []string{delegateeName}
+
+	// Optionally move the delegated targets into hash bin roles
+	if *hashBins > 0 {
+		binNames, bins := createHashBins(delegatee, keys, delegateeName, *hashBins, expireIn(30))
+		for _, binName := range binNames {
+			roles.SetTargets(binName, bins[binName])
+		}
+		delegatedRoles = append(delegatedRoles, binNames...)
+	}
+	if *succinctBitLength > 0 {
+		binNames, bins := createSuccinctHashBins(delegatee, keys, delegateeName, *succinctBitLength, expireIn(30))
+		for _, binName := range binNames {
+			roles.SetTargets(binName, bins[binName])
+		}
+		delegatedRoles = append(delegatedRoles, binNames...)
+	}
+
 	// Update snapshot to include delegated targets
 	roles.Snapshot().Signed.Meta["targets.json"] = metadata.MetaFile(1)
-	roles.Snapshot().Signed.Meta[fmt.Sprintf("%s.json", delegateeName)] = metadata.MetaFile(1)
+	for _, roleName := range delegatedRoles {
+		roles.Snapshot().Signed.Meta[fmt.Sprintf("%s.json", roleName)] = metadata.MetaFile(1)
+	}
 
 	// Update timestamp to reference snapshot
 	roles.Timestamp().Signed.Meta["snapshot.json"] = metadata.MetaFile(1)
 
 	// Sign all metadata
-	for _, roleName := range []string{"root", "targets", "snapshot", "timestamp", delegateeName} {
+	for _, roleName := range append([]string{"root", "targets", "snapshot", "timestamp"}, delegatedRoles...) {
 		key := keys[roleName]
 		signer, err := signature.LoadSigner(key, crypto.Hash(0))
 		if err != nil {
@@ -183,8 +244,8 @@ func main() {
 			_, err = roles.Snapshot().Sign(signer)
 		case "timestamp":
 			_, err = roles.Timestamp().Sign(signer)
-		case delegateeName:
-			_, err = roles.Targets(delegateeName).Sign(signer)
+		default:
+			_, err = roles.Targets(roleName).Sign(signer)
 		}
 
 		if err != nil {
@@ -199,17 +260,37 @@ func main() {
 	}
 	fmt.Println("✓ Created root.json")
 
-	err = roles.Targets("targets").ToFile(filepath.Join(repoDir, "targets.json"), true)
+	// Embed the new root as the service's root of trust
+	err = roles.Root().ToFile(*embeddedRoot, true)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to write targets.json: %v", err))
+		panic(fmt.Sprintf("Failed to write embedded root: %v", err))
 	}
-	fmt.Println("✓ Created targets.json")
+	fmt.Printf("✓ Created %s\n", *embeddedRoot)
 
-	err = roles.Snapshot().ToFile(filepath.Join(repoDir, "snapshot.json"), true)
-	if err != nil {
-		panic(fmt.Sprintf("Failed to write snapshot.json: %v", err))
+	// With consistent snapshots, clients read the versioned file names
+	fileNames := func(roleName string, version int64) []string {
+		names := []string{roleName + ".json"}
+		if *consistentSnapshot {
+			names = append(names, fmt.Sprintf("%d.%s.json", version, roleName))
+		}
+		return names
+	}
+
+	for _, fileName := range fileNames("targets", roles.Targets("targets").Signed.Version) {
+		err = roles.Targets("targets").ToFile(filepath.Join(repoDir, fileName), true)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to write %s: %v", fileName, err))
+		}
+		fmt.Printf("✓ Created %s\n", fileName)
+	}
+
+	for _, fileName := range fileNames("snapshot", roles.Snapshot().Signed.Version) {
+		err = roles.Snapshot().ToFile(filepath.Join(repoDir, fileName), true)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to write %s: %v", fileName, err))
+		}
+		fmt.Printf("✓ Created %s\n", fileName)
 	}
-	fmt.Println("✓ Created snapshot.json")
 
 	err = roles.Timestamp().ToFile(filepath.Join(repoDir, "timestamp.json"), true)
 	if err != nil {
@@ -217,11 +298,15 @@ func main() {
 	}
 	fmt.Println("✓ Created timestamp.json")
 
-	err = roles.Targets(delegateeName).ToFile(filepath.Join(repoDir, delegateeName+".json"), true)
-	if err != nil {
-		panic(fmt.Sprintf("Failed to write %s.json: %v", delegateeName, err))
+	for _, roleName := range delegatedRoles {
+		for _, fileName := range fileNames(roleName, roles.Targets(roleName).Signed.Version) {
+			err = roles.Targets(roleName).ToFile(filepath.Join(repoDir, fileName), true)
+			if err != nil {
+				panic(fmt.Sprintf("Failed to write %s: %v", fileName, err))
+			}
+			fmt.Printf("✓ Created %s\n", fileName)
+		}
 	}
-	fmt.Printf("✓ Created %s.json\n", delegateeName)
 
 	// Verify metadata signatures
 	fmt.Println("\nVerifying metadata signatures...")
@@ -256,11 +341,22 @@ func main() {
 	}
 	fmt.Printf("✓ %s metadata verified\n", delegateeName)
 
+	for _, roleName := range delegatedRoles[1:] {
+		err = roles.Targets(delegateeName).VerifyDelegate(roleName, roles.Targets(roleName))
+		if err != nil {
+			panic(fmt.Sprintf("Delegated targets verification failed for %s: %v", roleName, err))
+		}
+		fmt.Printf("✓ %s metadata verified\n", roleName)
+	}
+
 	fmt.Printf("\n🎉 TUF repository created successfully in %s\n", repoDir)
 	fmt.Println("\nDelegated paths for /v2/library/*:")
 	for _, path := range targetPaths {
 		fmt.Printf("  - %s\n", path)
 	}
+	if *syntheticTargets > 0 {
+		fmt.Printf("  - %d synthetic targets /v2/library/synthetic-N/manifests/latest\n", *syntheticTargets)
+	}
 }
 
 // createTestTargetFiles creates dummy target files for testing
@@ -281,3 +377,116 @@ func createTestTargetFiles(targetsDir string) {
 		}
 	}
 }
+
+// createHashBins moves the targets of the parent role into numBins hash bin
+// roles that parent delegates to with path_hash_prefixes, returning the bin
+// role names in delegation order and their metadata. All bins share a single
+// signing key.
+func createHashBins(parent *metadata.Metadata[metadata.TargetsType], keys map[string]ed25519.PrivateKey, parentName string, numBins int, expires time.Time) ([]string, map[string]*metadata.Metadata[metadata.TargetsType]) {
+	_, binPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to generate hash bin key: %v", err))
+	}
+
+	binKey, err := metadata.KeyFromPublicKey(binPrivateKey.Public())
+	if err != nil {
+		panic(fmt.Sprintf("Failed to convert hash bin key: %v", err))
+	}
+
+	// Use the shortest hex prefix that yields at least numBins prefixes, and
+	// give each bin an equal, consecutive share of them
+	prefixLen := 1
+	for 1<<(4*prefixLen) < numBins {
+		prefixLen++
+	}
+	numPrefixes := 1 << (4 * prefixLen)
+	prefixesPerBin := numPrefixes / numBins
+
+	parent.Signed.Delegations = &metadata.Delegations{
+		Keys: map[string]*metadata.Key{
+			binKey.ID(): binKey,
+		},
+	}
+
+	var binNames []string
+	bins := make(map[string]*metadata.Metadata[metadata.TargetsType])
+	binForPrefix := make(map[string]string)
+	for bin := 0; bin < numBins; bin++ {
+		var prefixes []string
+		for i := bin * prefixesPerBin; i < (bin+1)*prefixesPerBin; i++ {
+			prefix := fmt.Sprintf("%0*x", prefixLen, i)
+			prefixes = append(prefixes, prefix)
+			binForPrefix[prefix] = fmt.Sprintf("%s-bin-%d", parentName, bin)
+		}
+
+		binName := fmt.Sprintf("%s-bin-%d", parentName, bin)
+		binNames = append(binNames, binName)
+		keys[binName] = binPrivateKey
+		bins[binName] = metadata.Targets(expires)
+
+		parent.Signed.Delegations.Roles = append(parent.Signed.Delegations.Roles, metadata.DelegatedRole{
+			Name:             binName,
+			KeyIDs:           []string{binKey.ID()},
+			Threshold:        1,
+			PathHashPrefixes: prefixes,
+		})
+	}
+
+	// Move each target into the bin owning the prefix of its path hash
+	for targetPath, targetFileInfo := range parent.Signed.Targets {
+		digest := sha256.Sum256([]byte(targetPath))
+		binName := binForPrefix[hex.EncodeToString(digest[:])[:prefixLen]]
+		bins[binName].Signed.Targets[targetPath] = targetFileInfo
+	}
+	parent.Signed.Targets = map[string]*metadata.TargetFiles{}
+
+	return binNames, bins
+}
+
+// createSuccinctHashBins moves the targets of the parent role into 2^bitLength
+// hash bin roles that parent delegates to with a TAP 15 succinct delegation,
+// returning the bin role names in bin order and their metadata. All bins share
+// a single signing key.
+func createSuccinctHashBins(parent *metadata.Metadata[metadata.TargetsType], keys map[string]ed25519.PrivateKey, parentName string, bitLength int, expires time.Time) ([]string, map[string]*metadata.Metadata[metadata.TargetsType]) {
+	_, binPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to generate hash bin key: %v", err))
+	}
+
+	binKey, err := metadata.KeyFromPublicKey(binPrivateKey.Public())
+	if err != nil {
+		panic(fmt.Sprintf("Failed to convert hash bin key: %v", err))
+	}
+
+	succinct := &metadata.SuccinctRoles{
+		KeyIDs:     []string{binKey.ID()},
+		Threshold:  1,
+		BitLength:  bitLength,
+		NamePrefix: parentName + "-hb",
+	}
+	parent.Signed.Delegations = &metadata.Delegations{
+		Keys: map[string]*metadata.Key{
+			binKey.ID(): binKey,
+		},
+		SuccinctRoles: succinct,
+	}
+
+	// Every bin is published, including empty ones, so that each path
+	// resolves to a bin the client can load
+	binNames := succinct.GetRoles()
+	bins := make(map[string]*metadata.Metadata[metadata.TargetsType])
+	for _, binName := range binNames {
+		keys[binName] = binPrivateKey
+		bins[binName] = metadata.Targets(expires)
+	}
+
+	// Move each target into the bin its path hashes to
+	for targetPath, targetFileInfo := range parent.Signed.Targets {
+		for _, bin := range succinct.GetRolesForTarget(targetPath) {
+			bins[bin.Name].Signed.Targets[targetPath] = targetFileInfo
+		}
+	}
+	parent.Signed.Targets = map[string]*metadata.TargetFiles{}
+
+	return binNames, bins
+}